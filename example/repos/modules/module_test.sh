@@ -18,7 +18,7 @@ function internal_get_toCreate {
     compgen -v | grep "^EXEIAC_TEST_" | while read varname ; do
         field="$(sed 's/^EXEIAC_TEST_//g' <<<"$varname")"
         value="$(eval echo \"\$$varname\")"
-        if grep -q "^{" <<<"$value" ; then
+        if grep -q "^[{[]" <<<"$value" ; then
             echo -n "\"from_$field\": $value,"
         else
             echo -n "\"from_$field\": \"$value\","
@@ -51,6 +51,19 @@ function show_implemented_actions {
     exit 0
 }
 
+function describe {
+    jq --null-input \
+        --argjson actions "$(show_implemented_actions | jq --raw-input . | jq --slurp .)" \
+        '{
+            "protocol_version": 1,
+            "actions": $actions,
+            "read_only_actions": ["show_implemented_actions", "describe", "init", "plan", "output"],
+            "non_interactive_actions": ["lay", "remove"],
+            "required_tools": ["jq", "diff"],
+            "input_formats": ["json", "env"]
+        }'
+}
+
 function init {
     if which jq >/dev/null ; then
         echo "test-module:init: jq installed"
@@ -0,0 +1,193 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	exinfra "src/exeiac/infra"
+
+	"github.com/adrg/xdg"
+)
+
+// The directory, relative to the XDG state directory, where a directory of logs is created
+// for each run
+const LOGS_DIR = "exeiac/logs"
+
+// The number of stderr lines kept to be displayed in the summary of failed bricks
+const STDERR_TAIL_LENGTH = 10
+
+// The number of runs whose logs are kept, the logs of older runs are removed
+const LOGS_RUNS_KEPT = 50
+
+var (
+	runLogDir     string
+	runLogDirErr  error
+	runLogDirOnce sync.Once
+)
+
+// Returns the logs directory of the current run, creating it the first time and removing
+// the logs of the oldest runs.
+func getRunLogDir() (string, error) {
+	runLogDirOnce.Do(func() {
+		name := fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+		runLogDir = filepath.Join(xdg.StateHome, LOGS_DIR, name)
+		runLogDirErr = os.MkdirAll(runLogDir, 0700)
+		if runLogDirErr == nil {
+			pruneRunLogDirs(filepath.Dir(runLogDir))
+		}
+	})
+
+	return runLogDir, runLogDirErr
+}
+
+// Removes the logs of the runs older than the LOGS_RUNS_KEPT last ones.
+// NOTE(half-shell): runs directories are named after their start time, so sorting their
+// names sorts them by age. Failures are ignored since old logs are only taking some space.
+func pruneRunLogDirs(logsDir string) {
+	entries, err := os.ReadDir(logsDir)
+	if err != nil {
+		return
+	}
+
+	var runs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			runs = append(runs, e.Name())
+		}
+	}
+	sort.Strings(runs)
+
+	for len(runs) > LOGS_RUNS_KEPT {
+		os.RemoveAll(filepath.Join(logsDir, runs[0]))
+		runs = runs[1:]
+	}
+}
+
+// The log of a module's execution over a brick. Every line written by the module is
+// written in the log file with a timestamp, and the last lines of stderr are kept.
+// The log file is only created once a first line is written, so that bricks whose module
+// isn't executed don't have any.
+type brickLog struct {
+	// The log file path, "" if it hasn't been created
+	Path   string
+	brick  *exinfra.Brick
+	action string
+	// where to warn that the log file can't be created
+	stderr io.Writer
+	file   *os.File
+	// wheither or not the creation of the log file has already been tried
+	opened bool
+	mutex  sync.Mutex
+	// the last lines written in stderr
+	stderrTail []string
+	// the lines that haven't been ended yet, for each stream
+	pending map[string][]byte
+}
+
+// Returns the log of an action over a brick. Its file is created with the first line written.
+func newBrickLog(b *exinfra.Brick, action string, stderr io.Writer) *brickLog {
+	return &brickLog{
+		brick:   b,
+		action:  action,
+		stderr:  stderr,
+		pending: make(map[string][]byte),
+	}
+}
+
+// Creates the log file. If it can't be created, a warning is written in the log's `stderr`
+// and the outputs are only kept for the stderr tail.
+// NOTE(half-shell): must be called with the mutex locked
+func (log *brickLog) open() {
+	log.opened = true
+
+	dir, err := getRunLogDir()
+	if err == nil {
+		name := fmt.Sprintf("%s.%s.log", strings.ReplaceAll(log.brick.Name, "/", "_"), log.action)
+		log.file, err = os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	}
+	if err != nil {
+		fmt.Fprintf(log.stderr, "Warning: unable to create the log file of %s: %v\n", log.brick.Name, err)
+	} else {
+		log.Path = log.file.Name()
+	}
+}
+
+// Writes the complete lines of `p` in the log file, with a timestamp and the stream name.
+func (log *brickLog) write(stream string, p []byte) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	content := append(log.pending[stream], p...)
+	for {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			break
+		}
+		log.writeLine(stream, content[:i])
+		content = content[i+1:]
+	}
+	log.pending[stream] = append([]byte{}, content...)
+}
+
+// NOTE(half-shell): must be called with the mutex locked
+func (log *brickLog) writeLine(stream string, line []byte) {
+	if stream == "err" {
+		log.stderrTail = append(log.stderrTail, string(ansiEscapeRegexp.ReplaceAll(line, nil)))
+		if len(log.stderrTail) > STDERR_TAIL_LENGTH {
+			log.stderrTail = log.stderrTail[1:]
+		}
+	}
+
+	if !log.opened {
+		log.open()
+	}
+	if log.file != nil {
+		fmt.Fprintf(log.file, "%s [%s] %s\n", time.Now().Format(time.RFC3339Nano), stream, line)
+	}
+}
+
+type brickLogWriter struct {
+	log    *brickLog
+	stream string
+}
+
+func (w brickLogWriter) Write(p []byte) (int, error) {
+	w.log.write(w.stream, p)
+
+	return len(p), nil
+}
+
+// Returns writers writing both in `stdout` and `stderr` and in the log.
+func (log *brickLog) tee(stdout io.Writer, stderr io.Writer) (io.Writer, io.Writer) {
+	return io.MultiWriter(stdout, brickLogWriter{log: log, stream: "out"}),
+		io.MultiWriter(stderr, brickLogWriter{log: log, stream: "err"})
+}
+
+// Writes the lines that haven't been ended, closes the log file and sets the report's
+// LogFile and StderrTail.
+func (log *brickLog) close(report *ExecReport) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	for _, stream := range []string{"out", "err"} {
+		if len(log.pending[stream]) > 0 {
+			log.writeLine(stream, log.pending[stream])
+			log.pending[stream] = nil
+		}
+	}
+
+	if log.file != nil {
+		log.file.Close()
+		log.file = nil
+	}
+
+	report.LogFile = log.Path
+	report.StderrTail = log.stderrTail
+}
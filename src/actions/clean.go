@@ -2,10 +2,12 @@ package actions
 
 import (
 	"fmt"
+	"os"
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
 	extools "src/exeiac/tools"
+	"time"
 )
 
 func Clean(
@@ -16,15 +18,21 @@ func Clean(
 	statusCode int,
 	err error,
 ) {
+	redirectDisplay(conf)
+
 	if len(bricksToExecute) == 0 {
 		return exstatuscode.INIT_ERROR, exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for clean action"}
 	}
 
+	if conf.DryRun {
+		return dryRun(infra, conf, "clean", bricksToExecute, nil, false, true)
+	}
+
 	if conf.Interactive {
 		fmt.Println("Here, the bricks list to clean :")
 		fmt.Print(bricksToExecute)
 
-		confirm, err := extools.AskConfirmation("\nDo you want to continue ?")
+		confirm, err := exinfra.AskConfirmation("\nDo you want to continue ?")
 
 		if err != nil {
 			return exstatuscode.RUN_ERROR, err
@@ -33,7 +41,7 @@ func Clean(
 		}
 	}
 
-	err = enrichDatas(bricksToExecute, infra)
+	err = enrichDatas(bricksToExecute, infra, conf)
 	if err != nil {
 		return exstatuscode.ENRICH_ERROR, err
 	}
@@ -42,7 +50,17 @@ func Clean(
 
 	for i, b := range bricksToExecute {
 		extools.DisplaySeparator(b.Name)
-		report := ExecReport{Brick: b}
+		report := ExecReport{Brick: b, Action: "clean", ExitCode: -1}
+		if exinfra.IsInterrupted() {
+			report.Status = TAG_SKIP
+			report.Reason = REASON_INTERRUPTED
+			execSummary[i] = report
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
+			fmt.Printf("clean skipped: %s\n\n", REASON_INTERRUPTED)
+
+			continue
+		}
+		start := time.Now()
 		skipModuleClean := false
 
 		envs, err := writeEnvFilesAndGetEnvs(b)
@@ -55,7 +73,11 @@ func Clean(
 
 		// module clean
 		if !skipModuleClean {
-			exitStatus, err := b.Module.Exec(b, "clean", conf.OtherOptions, envs)
+			log := newBrickLog(b, "clean", os.Stderr)
+			stdout, stderr := log.tee(os.Stdout, os.Stderr)
+			exitStatus, err := b.Module.Exec(b, "clean", conf.OtherOptions, envs, stdout, stderr)
+			log.close(&report)
+			report.ExitCode = exitStatus
 			if err != nil {
 				if actionNotImplementedError, isActionNotImplemented := err.(exinfra.ActionNotImplementedError); isActionNotImplemented {
 					// NOTE(half-shell): if action if not implemented, we don't take it as an error
@@ -98,11 +120,11 @@ func Clean(
 			}
 		}
 
+		report.Duration = time.Since(start)
+		report.markInterrupted(os.Stderr)
 		execSummary[i] = report
 		fmt.Println("")
 	}
 
-	execSummary.Display()
-
-	return
+	return execSummary.Report(conf, statusCode)
 }
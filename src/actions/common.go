@@ -2,9 +2,11 @@ package actions
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
@@ -15,7 +17,10 @@ import (
 
 var BehaviourMap = map[string]func(*exinfra.Infra, *exargs.Configuration, exinfra.Bricks) (int, error){
 	"clean":         Clean,
+	"drift":         Drift,
+	"graph":         Graph,
 	"help":          Help,
+	"history":       History,
 	"init":          PassthroughAction,
 	"lay":           Lay,
 	"plan":          Plan,
@@ -34,6 +39,10 @@ const TAG_ERROR = "ERR"
 const TAG_SKIP = "SKIP"
 const TAG_DRIFT = "DRIFT"
 const TAG_MAY_DRIFT = "DRIFT?"
+const TAG_INTERRUPTED = "INTR"
+
+// The reason given to the bricks that haven't been started because exeiac has been interrupted
+const REASON_INTERRUPTED = "exeiac has been interrupted"
 
 type ExecSummary []ExecReport
 
@@ -41,44 +50,115 @@ type ExecReport struct {
 	Brick  *exinfra.Brick
 	Status string // "" red"ERR" blue"SKIP" green"OK" cyan"DONE" cyan"DRIFT"
 	Error  error  //
+	Action string
+	// Why the brick has been skipped
+	Reason string
+	// The module's exit code, -1 if the module hasn't been executed
+	ExitCode      int
+	Duration      time.Duration
+	OutputChanged bool
+	// The changes of the brick's output, and the direct next bricks consuming a changed value
+	OutputDiff     []exinfra.OutputChange
+	ImpactedBricks exinfra.Bricks
+	// The log file of the module's execution, and the last lines of its stderr
+	LogFile    string
+	StderrTail []string
+	// The module's outputs, only captured for the reports needing them (see captureOutputs)
+	Stdout []byte
+	Stderr []byte
 }
 
 func (es ExecSummary) Display() {
+	fmt.Print(es.summary(true))
+}
+
+// Returns the summary of the execution, with a line per brick starting with its status tag.
+func (es ExecSummary) summary(withColors bool) string {
 	var sb strings.Builder
 
-	sb.WriteString(color.New(color.Bold).Sprint("Summary:\n"))
+	bold := color.New(color.Bold)
+	if !withColors {
+		bold.DisableColor()
+	}
+
+	sb.WriteString(bold.Sprint("Summary:\n"))
 	for _, report := range es {
 		var str string
 		if report.Error != nil {
 			str = fmt.Sprintf("%s : %s", report.Brick.Name,
 				extools.IndentIfMultiline(report.Error.Error()))
+		} else if report.Reason != "" {
+			str = fmt.Sprintf("%s : %s", report.Brick.Name, report.Reason)
 		} else {
 			str = report.Brick.Name
 		}
-		switch report.Status {
-		case TAG_ERROR:
-			sb.WriteString(color.RedString("ERR     "))
-		case TAG_SKIP:
-			sb.WriteString(color.BlueString("SKIP    "))
-		case TAG_OK:
-			sb.WriteString(color.GreenString("OK      "))
-		case TAG_NO_CHANGE:
-			sb.WriteString(color.GreenString("OK      "))
-		case TAG_DONE:
-			sb.WriteString(color.CyanString("DONE    "))
-		case TAG_DRIFT:
-			sb.WriteString(color.CyanString("DRIFT   "))
-		case TAG_MAY_DRIFT:
-			sb.WriteString(color.CyanString("?DRIFT? "))
-		case "":
-			sb.WriteString(color.RedString("NO FLAG  "))
-		default:
-			sb.WriteString(color.YellowString(report.Status))
-		}
+		sb.WriteString(statusTag(report.Status, withColors))
 		sb.WriteString(fmt.Sprintf("%s\n", str))
+
+		if report.Status == TAG_ERROR || report.Status == TAG_INTERRUPTED {
+			for _, line := range report.StderrTail {
+				sb.WriteString(fmt.Sprintf("        | %s\n", line))
+			}
+			if report.LogFile != "" {
+				sb.WriteString(fmt.Sprintf("        log: %s\n", report.LogFile))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// Returns the tag displayed in front of a brick for the given status, padded to align bricks.
+func statusTag(status string, withColors bool) string {
+	tag := func(attribute color.Attribute, tag string) string {
+		c := color.New(attribute)
+		if !withColors {
+			c.DisableColor()
+		}
+
+		return c.Sprint(tag)
+	}
+
+	switch status {
+	case TAG_ERROR:
+		return tag(color.FgRed, "ERR     ")
+	case TAG_SKIP:
+		return tag(color.FgBlue, "SKIP    ")
+	case TAG_INTERRUPTED:
+		return tag(color.FgMagenta, "INTR    ")
+	case TAG_OK:
+		return tag(color.FgGreen, "OK      ")
+	case TAG_NO_CHANGE:
+		return tag(color.FgGreen, "OK      ")
+	case TAG_DONE:
+		return tag(color.FgCyan, "DONE    ")
+	case TAG_DRIFT:
+		return tag(color.FgCyan, "DRIFT   ")
+	case TAG_MAY_DRIFT:
+		return tag(color.FgCyan, "?DRIFT? ")
+	case "":
+		return tag(color.FgRed, "NO FLAG  ")
+	default:
+		return tag(color.FgYellow, status)
+	}
+}
+
+// Marks a failed brick as interrupted if exeiac has been interrupted during its execution,
+// and removes the input files written for its module.
+// Returns wheither or not the brick has been interrupted.
+func (report *ExecReport) markInterrupted(stderr io.Writer) bool {
+	if report.Status != TAG_ERROR || !exinfra.IsInterrupted() {
+		return false
 	}
 
-	fmt.Print(sb.String())
+	report.Status = TAG_INTERRUPTED
+	if report.Brick.IsElementary && report.Brick.EnrichError == nil {
+		if err := cleanEnvFiles(report.Brick); err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+		}
+	}
+
+	return true
 }
 
 func (es ExecSummary) String() string {
@@ -98,7 +178,35 @@ func (es ExecSummary) String() string {
 	return sb.String()
 }
 
-func enrichDatas(bricksToExecute exinfra.Bricks, infra *exinfra.Infra) error {
+// Gets the outputs of the bricks to execute and of all the bricks they depend on.
+// Outputs are read from the cache when the brick hasn't changed since they were fetched,
+// unless `conf.RefreshOutputs` is set.
+func enrichDatas(bricksToExecute exinfra.Bricks, infra *exinfra.Infra, conf *exargs.Configuration) error {
+	bricksToFetch, err := outputsToFetch(bricksToExecute, infra, conf)
+	if err != nil {
+		return err
+	}
+
+	for _, err := range fetchOutputs(infra, bricksToFetch, false) {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the bricks whose output has to be fetched for the bricks to execute: the bricks
+// to execute and all the bricks they depend on, whose output is neither already known nor
+// cached. Cached outputs are loaded on the way.
+func outputsToFetch(
+	bricksToExecute exinfra.Bricks,
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+) (
+	bricksToFetch exinfra.Bricks,
+	err error,
+) {
 	// find all bricks that we need to ask output
 	var neededBricksForTheirOutputs exinfra.Bricks
 	for _, b := range bricksToExecute {
@@ -108,7 +216,7 @@ func enrichDatas(bricksToExecute exinfra.Bricks, infra *exinfra.Infra) error {
 		}*/
 		bricks, err := infra.GetCorrespondingBricks(exinfra.Bricks{b}, []string{"selected", "linked_previous"})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		neededBricksForTheirOutputs = append(neededBricksForTheirOutputs, bricks...)
 	}
@@ -118,25 +226,116 @@ func enrichDatas(bricksToExecute exinfra.Bricks, infra *exinfra.Infra) error {
 	// check we don't have any enrich error on brick we will execute output
 	for _, b := range neededBricksForTheirOutputs {
 		if b.EnrichError != nil {
-			return b.EnrichError
+			return nil, b.EnrichError
 		}
 
-		envs, err := writeEnvFilesAndGetEnvs(b)
-		if err != nil {
-			return err
+		// NOTE(half-shell): outputs can already be known, e.g. when resuming a lay
+		if b.Output != nil && !conf.RefreshOutputs {
+			continue
 		}
 
-		stdout := exinfra.StoreStdout{}
-		statusCode, err := b.Module.Exec(b, "output", []string{}, envs, &stdout)
-		if err != nil {
-			return err
+		if !conf.RefreshOutputs {
+			if output, found := infra.LoadCachedOutput(b); found {
+				b.Output = output
+				continue
+			}
+		}
+
+		bricksToFetch = append(bricksToFetch, b)
+	}
+
+	return
+}
+
+// Executes the output action of the bricks, at most MAX_CONCURRENT_FETCHES at the same time.
+// A brick's output is only fetched once the outputs of the bricks it depends on (among
+// `bricks`) are known, since its input files are written before.
+// No output is fetched anymore once one has failed, unless `keepGoing` is true. In this
+// case, only the outputs of the bricks depending on the failed one aren't fetched.
+// Returns the error of each brick, ordered as `bricks`. Bricks whose output hasn't been
+// fetched because of another brick's failure have no error and a nil output.
+func fetchOutputs(infra *exinfra.Infra, bricks exinfra.Bricks, keepGoing bool) []error {
+	positions := make(map[*exinfra.Brick]int)
+	for i, b := range bricks {
+		positions[b] = i
+	}
+
+	// waitingFor[i] is the number of outputs bricks[i] still waits for, and unlocks[i]
+	// the positions of the bricks waiting for the output of bricks[i]
+	waitingFor := make([]int, len(bricks))
+	unlocks := make([][]int, len(bricks))
+	var ready []int
+	for i, b := range bricks {
+		dependencies, _ := infra.GetDirectPrevious(b)
+		for _, d := range exinfra.RemoveDuplicates(dependencies) {
+			if j, isFetched := positions[d]; isFetched && j != i {
+				waitingFor[i]++
+				unlocks[j] = append(unlocks[j], i)
+			}
+		}
+		if waitingFor[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	errs := make([]error, len(bricks))
+	done := make(chan int)
+	running := 0
+	failed := false
+	for {
+		for !failed && running < exinfra.MAX_CONCURRENT_FETCHES && len(ready) > 0 {
+			go func(position int) {
+				errs[position] = fetchOutput(infra, bricks[position])
+				done <- position
+			}(ready[0])
+			ready = ready[1:]
+			running++
+		}
+
+		if running == 0 {
+			break
 		}
 
-		if statusCode != 0 {
-			return fmt.Errorf("unable to get output of %s", b.Name)
+		position := <-done
+		running--
+		if errs[position] != nil {
+			failed = !keepGoing
+			continue
 		}
 
-		b.Output = stdout.Output
+		for _, i := range unlocks[position] {
+			waitingFor[i]--
+			if waitingFor[i] == 0 {
+				ready = append(ready, i)
+			}
+		}
+	}
+
+	return errs
+}
+
+// Executes the output action of a brick and caches its output
+func fetchOutput(infra *exinfra.Infra, b *exinfra.Brick) error {
+	envs, err := writeEnvFilesAndGetEnvs(b)
+	if err != nil {
+		return err
+	}
+
+	stdout := exinfra.StoreStdout{}
+	statusCode, err := b.Module.Exec(b, "output", []string{}, envs, &stdout)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != 0 {
+		return fmt.Errorf("unable to get output of %s", b.Name)
+	}
+
+	b.Output = stdout.Output
+
+	err = infra.CacheOutput(b)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to cache output of %s: %v\n", b.Name, err)
 	}
 
 	return nil
@@ -151,22 +350,29 @@ func writeEnvFilesAndGetEnvs(brick *exinfra.Brick) (envs []string, err error) {
 
 	if len(formatters) > 0 {
 		for path, formatter := range formatters {
-			var f *os.File
-			f, err = os.Create(path)
+			// NOTE(half-shell): the file must be formatted before being overwritten,
+			// since a MergingFormatter reads it
+			var data []byte
+			data, err = formatter.Format()
 			if err != nil {
 				return
 			}
 
-			var data []byte
-			data, err = formatter.Format()
-			_, err = f.Write(data)
+			if mergingFormatter, isMerging := formatter.(exinfra.MergingFormatter); isMerging {
+				err = mergingFormatter.SaveMerged()
+				if err != nil {
+					return
+				}
+			}
+
+			err = os.WriteFile(path, data, 0666)
 			if err != nil {
 				return
 			}
 		}
 	}
 
-	envs = envFormatter.Environ()
+	envs, err = envFormatter.Environ()
 	return
 }
 
@@ -177,7 +383,16 @@ func cleanEnvFiles(brick *exinfra.Brick) error {
 	}
 
 	if len(formatters) > 0 {
-		for path := range formatters {
+		for path, formatter := range formatters {
+			// only remove what has been merged in files we don't own
+			if mergingFormatter, isMerging := formatter.(exinfra.MergingFormatter); isMerging {
+				if err := mergingFormatter.Unmerge(); err != nil {
+					return fmt.Errorf("error when unmerging %s an input files of brick %s: %v", path, brick.Name, err)
+				}
+
+				continue
+			}
+
 			if _, err := os.Stat(path); err == nil {
 				err = os.Remove(path)
 				if err != nil {
@@ -2,10 +2,12 @@ package actions
 
 import (
 	"fmt"
+	"os"
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
 	extools "src/exeiac/tools"
+	"time"
 )
 
 // Triggers a module execution for very single brick in `bricksToExecute`
@@ -20,21 +22,44 @@ func PassthroughAction(
 	statusCode int,
 	err error,
 ) {
+	redirectDisplay(conf)
+
 	if len(bricksToExecute) == 0 {
 		return exstatuscode.INIT_ERROR,
 			exinfra.ErrBadArg{Reason: fmt.Sprintf("Error: you should specify at least a brick for %s action", conf.Action)}
 	}
 
+	if conf.DryRun {
+		return dryRun(infra, conf, conf.Action, bricksToExecute, nil, false, false)
+	}
+
 	execSummary := make(ExecSummary, len(bricksToExecute))
 
 	for i, b := range bricksToExecute {
 
 		extools.DisplaySeparator(b.Name)
-		report := ExecReport{Brick: b}
+		report := ExecReport{Brick: b, Action: conf.Action, ExitCode: -1}
+		if exinfra.IsInterrupted() {
+			report.Status = TAG_SKIP
+			report.Reason = REASON_INTERRUPTED
+			execSummary[i] = report
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
+			fmt.Printf("%s skipped: %s\n\n", conf.Action, REASON_INTERRUPTED)
+
+			continue
+		}
+		start := time.Now()
 
 		// NOTE(arthur91f): we may need to add:    envs, err := writeEnvFilesAndGetEnvs(b)
 		// it seems not necessary for init and validate code but who knows for other actions
-		exitStatus, err := b.Module.Exec(b, conf.Action, conf.OtherOptions, []string{})
+		log := newBrickLog(b, conf.Action, os.Stderr)
+		stdout, stderr := log.tee(os.Stdout, os.Stderr)
+		captured, stdout, stderr := captureOutputs(conf, stdout, stderr)
+		exitStatus, err := b.Module.Exec(b, conf.Action, conf.OtherOptions, []string{}, stdout, stderr)
+		captured.fill(&report)
+		log.close(&report)
+		report.ExitCode = exitStatus
+		report.Duration = time.Since(start)
 
 		if err != nil {
 			if actionNotImplementedError, isActionNotImplemented := err.(exinfra.ActionNotImplementedError); isActionNotImplemented {
@@ -56,11 +81,10 @@ func PassthroughAction(
 			report.Error = fmt.Errorf("module exit with status code %d", exitStatus)
 		}
 
+		report.markInterrupted(os.Stderr)
 		execSummary[i] = report
 		fmt.Println("")
 	}
 
-	execSummary.Display()
-
-	return
+	return execSummary.Report(conf, statusCode)
 }
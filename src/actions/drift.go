@@ -0,0 +1,286 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+
+	"github.com/fatih/color"
+)
+
+// Plans every elementary brick of the bricks to execute, or of the whole infra if none is
+// given, and displays a drift report grouped by rooms and super-bricks.
+// Bricks that can't be planned because of an enrich error, or because the output of a brick
+// they depend on can't be fetched, are reported as errors. The other bricks are planned.
+// Modules outputs are only displayed in the report, for the bricks that have drifted or failed.
+// The report format can be text (default) or markdown, e.g. to open an issue.
+func Drift(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+) (
+	statusCode int,
+	err error,
+) {
+	redirectDisplay(conf)
+
+	switch conf.Format {
+	case "all", "a", "markdown", "md":
+	default:
+		return exstatuscode.INIT_ERROR, exinfra.ErrBadArg{Reason: fmt.Sprintf(
+			"Error: format not valid for drift action: %s", conf.Format)}
+	}
+
+	if err = checkParallelism(conf); err != nil {
+		return exstatuscode.INIT_ERROR, err
+	}
+
+	var bricks exinfra.Bricks
+	if len(bricksToExecute) == 0 {
+		for _, b := range infra.Bricks {
+			if b.IsElementary {
+				bricks = append(bricks, b)
+			}
+		}
+		sort.Sort(bricks)
+	} else {
+		bricks = bricksToExecute
+	}
+
+	// bricks that can't be planned are reported without being executed
+	var bricksToPlan exinfra.Bricks
+	enrichErrors := make(map[*exinfra.Brick]error)
+	for _, b := range bricks {
+		if b.EnrichError != nil {
+			enrichErrors[b] = b.EnrichError
+		} else if _, linkedErr := infra.GetLinkedPrevious(b); linkedErr != nil {
+			enrichErrors[b] = fmt.Errorf("a brick it depends on is not valid: %v", linkedErr)
+		} else {
+			bricksToPlan = append(bricksToPlan, b)
+		}
+	}
+
+	outputErrors, err := fetchDriftOutputs(infra, conf, bricksToPlan)
+	if err != nil {
+		return exstatuscode.ENRICH_ERROR, err
+	}
+	var plannableBricks exinfra.Bricks
+	for _, b := range bricksToPlan {
+		if _, hasFailed := outputErrors[b]; !hasFailed {
+			plannableBricks = append(plannableBricks, b)
+		}
+	}
+
+	plan := planTask(conf)
+	// NOTE(half-shell): modules outputs are only displayed in the report, and progress is
+	// displayed on stderr to keep stdout for the report
+	planSummary, statusCode := executeBricks(infra, plannableBricks, "plan", conf.Parallelism, false,
+		conf.KeepGoing, true, func(b *exinfra.Brick, stdout io.Writer, stderr io.Writer) (
+			report ExecReport, statusCode int, skipFollowing bool,
+		) {
+			var stdoutBuffer, stderrBuffer bytes.Buffer
+			report, statusCode, skipFollowing = plan(b,
+				io.MultiWriter(stdout, &stdoutBuffer), io.MultiWriter(stderr, &stderrBuffer))
+			report.Stdout = ansiEscapeRegexp.ReplaceAll(stdoutBuffer.Bytes(), nil)
+			report.Stderr = ansiEscapeRegexp.ReplaceAll(stderrBuffer.Bytes(), nil)
+
+			return
+		})
+
+	var execSummary ExecSummary
+	for _, b := range bricks {
+		if enrichErr, isInvalid := enrichErrors[b]; isInvalid {
+			execSummary = append(execSummary, ExecReport{
+				Brick:    b,
+				Status:   TAG_ERROR,
+				Error:    enrichErr,
+				Action:   "plan",
+				ExitCode: -1,
+			})
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.ENRICH_ERROR)
+		} else if outputErr, hasFailed := outputErrors[b]; hasFailed {
+			execSummary = append(execSummary, ExecReport{
+				Brick:    b,
+				Status:   TAG_ERROR,
+				Error:    outputErr,
+				Action:   "plan",
+				ExitCode: -1,
+			})
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
+		} else {
+			execSummary = append(execSummary, planSummary[0])
+			planSummary = planSummary[1:]
+		}
+	}
+
+	return execSummary.report(conf, statusCode, func() {
+		rooms := groupBricks(infra, bricks)
+		if conf.Format == "markdown" || conf.Format == "md" {
+			fmt.Print(formatMarkdownDriftReport(rooms, execSummary))
+		} else {
+			fmt.Print(formatDriftReport(rooms, execSummary))
+		}
+	})
+}
+
+// Fetches the outputs the bricks to plan need, carrying on when an output can't be fetched.
+// Returns, for each brick that can't be planned because of it, the error of the first
+// output it needs that has failed.
+func fetchDriftOutputs(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToPlan exinfra.Bricks,
+) (
+	outputErrors map[*exinfra.Brick]error,
+	err error,
+) {
+	bricksToFetch, err := outputsToFetch(bricksToPlan, infra, conf)
+	if err != nil {
+		return
+	}
+
+	fetchErrors := make(map[*exinfra.Brick]error)
+	for i, fetchErr := range fetchOutputs(infra, bricksToFetch, true) {
+		if fetchErr != nil {
+			fetchErrors[bricksToFetch[i]] = fetchErr
+		}
+	}
+
+	outputErrors = make(map[*exinfra.Brick]error)
+	for _, b := range bricksToPlan {
+		previousBricks, _ := infra.GetLinkedPrevious(b)
+		for _, p := range previousBricks {
+			if fetchErr, hasFailed := fetchErrors[p]; hasFailed {
+				outputErrors[b] = fmt.Errorf("unable to get the output of %s: %v", p.Name, fetchErr)
+				break
+			} else if p.Output == nil {
+				outputErrors[b] = fmt.Errorf("unable to get the output of %s: "+
+					"a brick it depends on has failed", p.Name)
+			}
+		}
+	}
+
+	return
+}
+
+// Counts the bricks of each status, to display the report's headline
+func countDriftStatuses(execSummary ExecSummary) string {
+	counts := make(map[string]int)
+	for _, r := range execSummary {
+		counts[r.Status]++
+	}
+
+	return fmt.Sprintf("%d drifted, %d may have drifted, %d in error, %d without drift",
+		counts[TAG_DRIFT], counts[TAG_MAY_DRIFT], counts[TAG_ERROR]+counts[TAG_INTERRUPTED], counts[TAG_NO_CHANGE])
+}
+
+func formatDriftReport(rooms []*graphGroup, execSummary ExecSummary) string {
+	var sb strings.Builder
+
+	reports := make(map[*exinfra.Brick]ExecReport)
+	for _, r := range execSummary {
+		reports[r.Brick] = r
+	}
+
+	var writeGroup func(group *graphGroup, indent string)
+	writeGroup = func(group *graphGroup, indent string) {
+		sb.WriteString(fmt.Sprintf("%s%s\n", indent, color.New(color.Bold).Sprint(group.Brick.Name)))
+
+		for _, g := range group.Groups {
+			writeGroup(g, indent+"  ")
+		}
+		for _, b := range group.Bricks {
+			r := reports[b]
+			sb.WriteString(fmt.Sprintf("%s  %s%s", indent, statusTag(r.Status, true), shortBrickName(b)))
+			if r.Error != nil {
+				sb.WriteString(fmt.Sprintf(" : %s", strings.ReplaceAll(r.Error.Error(), "\n", " ")))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString(color.New(color.Bold).Sprint("Drift report:\n"))
+	for _, room := range rooms {
+		writeGroup(room, "")
+	}
+	sb.WriteString(countDriftStatuses(execSummary) + "\n")
+
+	return sb.String()
+}
+
+// Returns a markdown code block containing `content`, whatever backquotes it contains
+func markdownCodeBlock(content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n", fence, strings.TrimRight(content, "\n"), fence)
+}
+
+func formatMarkdownDriftReport(rooms []*graphGroup, execSummary ExecSummary) string {
+	var sb strings.Builder
+
+	reports := make(map[*exinfra.Brick]ExecReport)
+	for _, r := range execSummary {
+		reports[r.Brick] = r
+	}
+
+	var writeGroup func(group *graphGroup, level int)
+	writeGroup = func(group *graphGroup, level int) {
+		if level > 6 {
+			level = 6
+		}
+		sb.WriteString(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", level), group.Brick.Name))
+
+		if len(group.Bricks) > 0 {
+			sb.WriteString("| Brick | Status | Error |\n")
+			sb.WriteString("| --- | --- | --- |\n")
+			for _, b := range group.Bricks {
+				r := reports[b]
+				errorMessage := ""
+				if r.Error != nil {
+					errorMessage = strings.ReplaceAll(
+						strings.ReplaceAll(r.Error.Error(), "|", "\\|"), "\n", " ")
+				}
+				sb.WriteString(fmt.Sprintf("| `%s` | %s | %s |\n", shortBrickName(b), r.Status, errorMessage))
+			}
+			sb.WriteString("\n")
+
+			for _, b := range group.Bricks {
+				r := reports[b]
+				output := r.Stdout
+				if r.Status == TAG_ERROR || r.Status == TAG_INTERRUPTED {
+					output = r.Stderr
+				} else if r.Status != TAG_DRIFT && r.Status != TAG_MAY_DRIFT {
+					continue
+				}
+				if len(bytes.TrimSpace(output)) == 0 {
+					continue
+				}
+
+				sb.WriteString(fmt.Sprintf("<details><summary>%s (%s)</summary>\n\n", b.Name, r.Status))
+				sb.WriteString(markdownCodeBlock(string(output)))
+				sb.WriteString("\n</details>\n\n")
+			}
+		}
+
+		for _, g := range group.Groups {
+			writeGroup(g, level+1)
+		}
+	}
+
+	sb.WriteString("# Drift report\n\n")
+	sb.WriteString(countDriftStatuses(execSummary) + "\n\n")
+	for _, room := range rooms {
+		writeGroup(room, 2)
+	}
+
+	return sb.String()
+}
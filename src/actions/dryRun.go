@@ -0,0 +1,224 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+	extools "src/exeiac/tools"
+)
+
+type dryRunInput struct {
+	VarName string `json:"var_name"`
+	Format  string `json:"format"`
+	// The file the input is written in, or "" if it is an environment variable
+	Path   string `json:"path,omitempty"`
+	Origin string `json:"origin"`
+}
+
+type dryRunBrick struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Module string `json:"module"`
+	Action string `json:"action"`
+	// Wheither the module implements the action
+	Implemented bool `json:"implemented"`
+	// Wheither the module declares that the action doesn't change anything
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Wheither the brick is only laid if a value it consumes has changed
+	Conditional bool `json:"conditional,omitempty"`
+	// The bricks that have to be done before this one
+	WaitsFor []string      `json:"waits_for"`
+	Inputs   []dryRunInput `json:"inputs"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type dryRunOutput struct {
+	Brick string `json:"brick"`
+	// "known", "cached" or "fetch"
+	Source string `json:"source"`
+}
+
+type dryRunPlan struct {
+	Action string        `json:"action"`
+	Bricks []dryRunBrick `json:"bricks"`
+	// The outputs needed to write the bricks inputs
+	Outputs []dryRunOutput `json:"outputs"`
+}
+
+// Displays what an action would do on the bricks to execute, in their execution order,
+// without executing any module.
+// `conditionalBricks` are the bricks only laid if a value they consume has changed, and
+// `needsOutputs` tells if the action fetches the outputs of the bricks it depends on.
+// The plan is displayed as text, or as JSON if the report format is json.
+func dryRun(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	action string,
+	bricksToExecute exinfra.Bricks,
+	conditionalBricks exinfra.Bricks,
+	reverse bool,
+	needsOutputs bool,
+) (
+	statusCode int,
+	err error,
+) {
+	plan := dryRunPlan{Action: action, Bricks: []dryRunBrick{}, Outputs: []dryRunOutput{}}
+
+	for _, b := range bricksToExecute {
+		brick := dryRunBrick{
+			Name:        b.Name,
+			Path:        b.Path,
+			Module:      moduleName(b),
+			Action:      action,
+			Conditional: conditionalBricks.BricksContains(b),
+			WaitsFor:    []string{},
+			Inputs:      []dryRunInput{},
+		}
+		if b.Module != nil {
+			brick.Implemented = extools.ContainsString(b.Module.Actions, action)
+			brick.ReadOnly = b.Module.IsReadOnly(action)
+		}
+		if b.EnrichError != nil {
+			brick.Error = b.EnrichError.Error()
+		}
+
+		var dependencies exinfra.Bricks
+		if reverse {
+			dependencies, _ = infra.GetDirectNext(b)
+		} else {
+			dependencies, _ = infra.GetDirectPrevious(b)
+		}
+		for _, d := range exinfra.RemoveDuplicates(dependencies) {
+			if bricksToExecute.BricksContains(d) && d != b {
+				brick.WaitsFor = append(brick.WaitsFor, d.Name)
+			}
+		}
+
+		for _, i := range b.Inputs {
+			input := dryRunInput{
+				VarName: i.VarName,
+				Format:  string(i.Format),
+				Origin:  i.Origin(),
+			}
+			if path := filepath.Join(b.Path, i.Path); path != b.Path {
+				input.Path = path
+			}
+			brick.Inputs = append(brick.Inputs, input)
+		}
+		sort.SliceStable(brick.Inputs, func(i, j int) bool {
+			return brick.Inputs[i].Path < brick.Inputs[j].Path
+		})
+
+		plan.Bricks = append(plan.Bricks, brick)
+	}
+
+	if needsOutputs {
+		plan.Outputs, err = getDryRunOutputs(infra, conf, bricksToExecute)
+		if err != nil {
+			return exstatuscode.ENRICH_ERROR, err
+		}
+	}
+
+	if conf.ReportFormat == "json" {
+		var content []byte
+		content, err = json.MarshalIndent(plan, "", "\t")
+		if err != nil {
+			return exstatuscode.RUN_ERROR, err
+		}
+		fmt.Fprintln(reportOutput, string(content))
+	} else {
+		fmt.Print(plan.String())
+	}
+
+	return
+}
+
+// Returns the outputs needed by the bricks to execute, as enrichDatas would fetch them
+func getDryRunOutputs(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+) (
+	outputs []dryRunOutput,
+	err error,
+) {
+	outputs = []dryRunOutput{}
+
+	neededBricks, err := infra.GetCorrespondingBricks(bricksToExecute, []string{"selected", "linked_previous"})
+	if err != nil {
+		return
+	}
+
+	for _, b := range neededBricks {
+		output := dryRunOutput{Brick: b.Name, Source: "fetch"}
+		if conf.RefreshOutputs {
+			outputs = append(outputs, output)
+			continue
+		}
+
+		if b.Output != nil {
+			output.Source = "known"
+		} else if b.EnrichError == nil {
+			if _, found := infra.LoadCachedOutput(b); found {
+				output.Source = "cached"
+			}
+		}
+		outputs = append(outputs, output)
+	}
+
+	return
+}
+
+func (plan dryRunPlan) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Dry run of %s on %d bricks:\n", plan.Action, len(plan.Bricks)))
+	for i, b := range plan.Bricks {
+		implemented := ""
+		if !b.Implemented {
+			implemented = ", not implemented"
+		} else if b.ReadOnly {
+			implemented = ", read-only"
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s (module %s: %s%s)\n", i+1, b.Name, b.Module, b.Action, implemented))
+		if b.Error != "" {
+			sb.WriteString(fmt.Sprintf("   error: %s\n", extools.IndentIfMultiline(b.Error)))
+		}
+		if b.Conditional {
+			sb.WriteString("   only if a value it consumes has changed\n")
+		}
+		if len(b.WaitsFor) > 0 {
+			sb.WriteString(fmt.Sprintf("   waits for: %s\n", strings.Join(b.WaitsFor, ", ")))
+		}
+		for _, input := range b.Inputs {
+			if input.Path == "" {
+				sb.WriteString(fmt.Sprintf("   env var %s: %s\n", input.VarName, input.Origin))
+			} else {
+				sb.WriteString(fmt.Sprintf("   file %s (%s) %s: %s\n",
+					input.Path, input.Format, input.VarName, input.Origin))
+			}
+		}
+	}
+
+	if len(plan.Outputs) > 0 {
+		sb.WriteString("Outputs needed:\n")
+		for _, o := range plan.Outputs {
+			switch o.Source {
+			case "known":
+				sb.WriteString(fmt.Sprintf("   %s (already known)\n", o.Brick))
+			case "cached":
+				sb.WriteString(fmt.Sprintf("   %s (cached)\n", o.Brick))
+			default:
+				sb.WriteString(fmt.Sprintf("   %s (fetched with the output action)\n", o.Brick))
+			}
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,269 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+	extools "src/exeiac/tools"
+)
+
+// A room or a super-brick containing some of the bricks to display
+type graphGroup struct {
+	Brick  *exinfra.Brick
+	Groups []*graphGroup
+	Bricks exinfra.Bricks
+}
+
+type graphEdge struct {
+	Previous *exinfra.Brick
+	Next     *exinfra.Brick
+	// The names of the variables creating the dependency
+	VarNames []string
+}
+
+type jsonGraphBrick struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Room        string   `json:"room"`
+	SuperBricks []string `json:"super_bricks"`
+	Module      string   `json:"module"`
+}
+
+type jsonGraphDependency struct {
+	Previous string   `json:"previous"`
+	Next     string   `json:"next"`
+	VarNames []string `json:"var_names"`
+}
+
+type jsonGraph struct {
+	Bricks       []jsonGraphBrick      `json:"bricks"`
+	Dependencies []jsonGraphDependency `json:"dependencies"`
+}
+
+// Displays the dependency graph of the bricks to execute, grouped by rooms and super-bricks.
+// Only the dependencies between those bricks are displayed.
+// The format can be Graphviz's DOT (default), Mermaid or JSON.
+func Graph(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+) (
+	statusCode int,
+	err error,
+) {
+	if len(bricksToExecute) == 0 {
+		err = exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for graph action"}
+
+		return exstatuscode.INIT_ERROR, err
+	}
+
+	var edges []graphEdge
+	for _, b := range bricksToExecute {
+		for _, d := range infra.GetDependencies(b) {
+			if !bricksToExecute.BricksContains(d.Previous) {
+				continue
+			}
+
+			var varNames []string
+			for _, i := range d.Inputs {
+				varNames = append(varNames, i.VarName)
+			}
+			edges = append(edges, graphEdge{
+				Previous: d.Previous,
+				Next:     b,
+				VarNames: extools.Deduplicate(varNames),
+			})
+		}
+	}
+
+	switch conf.Format {
+	case "dot", "all", "a":
+		fmt.Print(formatDotGraph(groupBricks(infra, bricksToExecute), edges))
+	case "mermaid":
+		fmt.Print(formatMermaidGraph(groupBricks(infra, bricksToExecute), edges, bricksToExecute))
+	case "json":
+		var graph []byte
+		graph, err = formatJsonGraph(infra, bricksToExecute, edges)
+		if err != nil {
+			return exstatuscode.RUN_ERROR, err
+		}
+		fmt.Println(string(graph))
+	default:
+		statusCode = exstatuscode.INIT_ERROR
+		err = exinfra.ErrBadArg{Reason: fmt.Sprintf(
+			"Error: format not valid for graph action: %s", conf.Format)}
+	}
+
+	return
+}
+
+// Builds the tree of rooms and super-bricks containing the given bricks.
+// Returns the groups matching rooms.
+func groupBricks(infra *exinfra.Infra, bricks exinfra.Bricks) (rooms []*graphGroup) {
+	groups := make(map[*exinfra.Brick]*graphGroup)
+
+	for _, b := range bricks {
+		var parent *graphGroup
+		for _, superBrick := range infra.GetSuperBricks(b) {
+			group, exist := groups[superBrick]
+			if !exist {
+				group = &graphGroup{Brick: superBrick}
+				groups[superBrick] = group
+				if parent == nil {
+					rooms = append(rooms, group)
+				} else {
+					parent.Groups = append(parent.Groups, group)
+				}
+			}
+			parent = group
+		}
+
+		if parent == nil {
+			// NOTE(half-shell): an elementary room doesn't have any super-brick
+			rooms = append(rooms, &graphGroup{Brick: b, Bricks: exinfra.Bricks{b}})
+		} else {
+			parent.Bricks = append(parent.Bricks, b)
+		}
+	}
+
+	return
+}
+
+// Returns the last part of a brick name, relatively to its super-brick
+func shortBrickName(b *exinfra.Brick) string {
+	return b.Name[strings.LastIndex(b.Name, "/")+1:]
+}
+
+// Escapes the backslashes and double quotes of a string to put it in a DOT quoted string
+func dotEscape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "\"", "\\\"")
+}
+
+func dotQuote(s string) string {
+	return "\"" + dotEscape(s) + "\""
+}
+
+// Quotes lines into a single DOT string, separated by the DOT line break escape sequence
+func dotMultilineQuote(lines []string) string {
+	var escaped []string
+	for _, line := range lines {
+		escaped = append(escaped, dotEscape(line))
+	}
+
+	return "\"" + strings.Join(escaped, "\\n") + "\""
+}
+
+func formatDotGraph(rooms []*graphGroup, edges []graphEdge) string {
+	var sb strings.Builder
+	clusterCount := 0
+
+	var writeGroup func(group *graphGroup, indent string)
+	writeGroup = func(group *graphGroup, indent string) {
+		sb.WriteString(fmt.Sprintf("%ssubgraph cluster_%d {\n", indent, clusterCount))
+		sb.WriteString(fmt.Sprintf("%s\tlabel=%s;\n", indent, dotQuote(shortBrickName(group.Brick))))
+		clusterCount++
+
+		for _, g := range group.Groups {
+			writeGroup(g, indent+"\t")
+		}
+		for _, b := range group.Bricks {
+			sb.WriteString(fmt.Sprintf("%s\t%s [label=%s];\n",
+				indent, dotQuote(b.Name), dotQuote(shortBrickName(b))))
+		}
+
+		sb.WriteString(indent + "}\n")
+	}
+
+	sb.WriteString("digraph exeiac {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	for _, room := range rooms {
+		writeGroup(room, "\t")
+	}
+	for _, e := range edges {
+		sb.WriteString(fmt.Sprintf("\t%s -> %s [label=%s];\n",
+			dotQuote(e.Previous.Name), dotQuote(e.Next.Name),
+			dotMultilineQuote(e.VarNames)))
+	}
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+func mermaidQuote(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "#quot;") + "\""
+}
+
+func formatMermaidGraph(rooms []*graphGroup, edges []graphEdge, bricks exinfra.Bricks) string {
+	var sb strings.Builder
+	groupCount := 0
+
+	// NOTE(half-shell): Mermaid's ids can't contain "/" so we use the bricks positions
+	ids := make(map[*exinfra.Brick]string)
+	for i, b := range bricks {
+		ids[b] = fmt.Sprintf("brick%d", i)
+	}
+
+	var writeGroup func(group *graphGroup, indent string)
+	writeGroup = func(group *graphGroup, indent string) {
+		sb.WriteString(fmt.Sprintf("%ssubgraph group%d [%s]\n",
+			indent, groupCount, mermaidQuote(shortBrickName(group.Brick))))
+		groupCount++
+
+		for _, g := range group.Groups {
+			writeGroup(g, indent+"\t")
+		}
+		for _, b := range group.Bricks {
+			sb.WriteString(fmt.Sprintf("%s\t%s[%s]\n", indent, ids[b], mermaidQuote(shortBrickName(b))))
+		}
+
+		sb.WriteString(indent + "end\n")
+	}
+
+	sb.WriteString("flowchart LR\n")
+	for _, room := range rooms {
+		writeGroup(room, "\t")
+	}
+	for _, e := range edges {
+		sb.WriteString(fmt.Sprintf("\t%s -->|%s| %s\n",
+			ids[e.Previous], mermaidQuote(strings.Join(e.VarNames, ", ")), ids[e.Next]))
+	}
+
+	return sb.String()
+}
+
+func formatJsonGraph(infra *exinfra.Infra, bricks exinfra.Bricks, edges []graphEdge) ([]byte, error) {
+	graph := jsonGraph{
+		Bricks:       []jsonGraphBrick{},
+		Dependencies: []jsonGraphDependency{},
+	}
+
+	for _, b := range bricks {
+		brick := jsonGraphBrick{
+			Name:        b.Name,
+			Path:        b.Path,
+			Room:        b.Room.Name,
+			SuperBricks: []string{},
+			Module:      b.Module.Name,
+		}
+		for _, sb := range infra.GetSuperBricks(b) {
+			if sb != b.Room {
+				brick.SuperBricks = append(brick.SuperBricks, sb.Name)
+			}
+		}
+		graph.Bricks = append(graph.Bricks, brick)
+	}
+
+	for _, e := range edges {
+		graph.Dependencies = append(graph.Dependencies, jsonGraphDependency{
+			Previous: e.Previous.Name,
+			Next:     e.Next.Name,
+			VarNames: e.VarNames,
+		})
+	}
+
+	return json.MarshalIndent(graph, "", "\t")
+}
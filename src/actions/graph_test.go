@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"strings"
+	"testing"
+
+	exinfra "src/exeiac/infra"
+)
+
+func TestFormatDotGraphEdgeLabels(t *testing.T) {
+	previous := &exinfra.Brick{Name: "room/a"}
+	next := &exinfra.Brick{Name: "room/b"}
+
+	tests := []struct {
+		name     string
+		varNames []string
+		expected string
+	}{
+		{
+			name:     "single variable",
+			varNames: []string{"ip"},
+			expected: `"room/a" -> "room/b" [label="ip"];`,
+		},
+		{
+			name:     "several variables on several lines",
+			varNames: []string{"ip", "port"},
+			expected: `"room/a" -> "room/b" [label="ip\nport"];`,
+		},
+		{
+			name:     "escaped variables",
+			varNames: []string{`a"b`, `c\d`},
+			expected: `"room/a" -> "room/b" [label="a\"b\nc\\d"];`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			graph := formatDotGraph(nil, []graphEdge{{Previous: previous, Next: next, VarNames: test.varNames}})
+			if !strings.Contains(graph, test.expected) {
+				t.Errorf("formatDotGraph() = %s, expected an edge %s", graph, test.expected)
+			}
+		})
+	}
+}
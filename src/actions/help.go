@@ -20,12 +20,21 @@ help: display this help or the specified help for the brick
 cd: change directory but you can use brick name althought path
 show: display brick attributes (depends of the format option choosen)
 clean: remove all files created by exeiac
+graph: display the dependency graph of bricks (depends of the format option
+    choosen: dot, mermaid or json)
+drift: plan every brick of the given rooms (all rooms if none is given) and
+    display a report of the bricks that have drifted (text or markdown format)
+history: display the lay and remove executions recorded for bricks (all bricks
+    if none is given), use --since and --until to select a time range
 OPTIONS:
 -I --non-interactive: run without interaction (use especially for ignore
                       confirmation after lay or remove)
 -s --bricks-specifier: (selected|previous|following|children|this|
                         recursive-following|recursive-precedents)
 -f --format: (name|path|input|output) use with show
+              (dot|mermaid|json) use with graph
+              (all|json) use with history
+              (all|markdown) use with drift
 `
 
 func Help(
@@ -0,0 +1,370 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+
+	"github.com/adrg/xdg"
+)
+
+// The directory, relative to the XDG state directory, where the history of each room is stored
+const HISTORY_DIR = "exeiac/history"
+
+// The hashes of a brick's output before and after an execution, recorded only if it has
+// changed. Outputs can contain secrets, so their values are never recorded.
+type historyOutputDiff struct {
+	BeforeHash string `json:"before_hash"`
+	AfterHash  string `json:"after_hash"`
+}
+
+// A line of a room's history
+type HistoryRecord struct {
+	Time   time.Time `json:"time"`
+	Brick  string    `json:"brick"`
+	Action string    `json:"action"`
+	User   string    `json:"user"`
+	Host   string    `json:"host"`
+	// The commit the room's repository was on, if it is a git repository
+	Commit string `json:"commit,omitempty"`
+	// A hash of the environment variables and input files given to the module
+	InputHash  string             `json:"input_hash"`
+	OutputDiff *historyOutputDiff `json:"output_diff,omitempty"`
+	// The paths of the output's values that have changed, when it is valid JSON
+	OutputChanges []exinfra.OutputChange `json:"output_changes,omitempty"`
+	Status        string                 `json:"status"`
+	// The module's exit code, -1 if the module hasn't been executed
+	ExitCode   int    `json:"exit_code"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+var historyMutex sync.Mutex
+
+// The rooms commits, computed once per run
+var roomsCommits = make(map[*exinfra.Brick]string)
+
+// Returns the history file of a room. It is named after the room, and a hash of exeiac's
+// configuration file and of the room's path, so that rooms with the same name in different
+// infras or checkouts don't share their history.
+func historyFilePath(infra *exinfra.Infra, room *exinfra.Brick) (string, error) {
+	name := strings.ReplaceAll(room.Name, string(filepath.Separator), "_")
+	hash := sha256.Sum256([]byte(infra.ConfigurationFile + "\x00" + room.Path))
+
+	return xdg.StateFile(filepath.Join(HISTORY_DIR, fmt.Sprintf("%s-%s.jsonl", name, hex.EncodeToString(hash[:8]))))
+}
+
+// Returns the commit the room's repository is on, or "" if it isn't a git repository.
+// NOTE(half-shell): must be called with the historyMutex locked
+func getRoomCommit(room *exinfra.Brick) string {
+	if commit, exist := roomsCommits[room]; exist {
+		return commit
+	}
+
+	out, err := exec.Command("git", "-C", room.Path, "rev-parse", "HEAD").Output()
+	commit := ""
+	if err == nil {
+		commit = strings.TrimSpace(string(out))
+	}
+	roomsCommits[room] = commit
+
+	return commit
+}
+
+func getUserName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+// Computes a hash of the environment variables and of the input files given to the module
+func hashInputs(b *exinfra.Brick, envs []string) string {
+	hash := sha256.New()
+
+	sortedEnvs := append([]string{}, envs...)
+	sort.Strings(sortedEnvs)
+	for _, env := range sortedEnvs {
+		fmt.Fprintf(hash, "env:%s\n", env)
+	}
+
+	paths := b.InputFilePaths()
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(hash, "file:%s\n", path)
+		if content, err := os.ReadFile(path); err == nil {
+			hash.Write(content)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns a hash of a brick's output, or "" if it has none
+func hashOutput(output []byte) string {
+	if output == nil {
+		return ""
+	}
+	hash := sha256.Sum256(output)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// Appends the record of a brick's execution to the history of its room.
+// Failures are only displayed as warnings on `stderr` since they shouldn't stop the execution.
+func recordHistory(
+	infra *exinfra.Infra,
+	b *exinfra.Brick,
+	action string,
+	start time.Time,
+	envs []string,
+	outputBefore []byte,
+	report ExecReport,
+	stderr io.Writer,
+) {
+	host, _ := os.Hostname()
+	record := HistoryRecord{
+		Time:          start.UTC(),
+		Brick:         b.Name,
+		Action:        action,
+		User:          getUserName(),
+		Host:          host,
+		InputHash:     hashInputs(b, envs),
+		Status:        report.Status,
+		OutputChanges: report.OutputDiff,
+		ExitCode:      report.ExitCode,
+		DurationMs:    time.Since(start).Milliseconds(),
+	}
+	if report.Error != nil {
+		record.Error = report.Error.Error()
+	}
+	if !bytes.Equal(outputBefore, b.Output) {
+		record.OutputDiff = &historyOutputDiff{
+			BeforeHash: hashOutput(outputBefore),
+			AfterHash:  hashOutput(b.Output),
+		}
+	}
+
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+
+	record.Commit = getRoomCommit(b.Room)
+
+	err := appendHistoryRecord(infra, b.Room, record)
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: unable to record %s of %s in history: %v\n", action, b.Name, err)
+	}
+}
+
+func appendHistoryRecord(infra *exinfra.Infra, room *exinfra.Brick, record HistoryRecord) error {
+	path, err := historyFilePath(infra, room)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+
+	return err
+}
+
+// Reads the history of a room. Returns no record if there isn't any history yet.
+func readHistory(infra *exinfra.Infra, room *exinfra.Brick) (records []HistoryRecord, err error) {
+	path, err := historyFilePath(infra, room)
+	if err != nil {
+		return
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// NOTE(half-shell): records listing many changed values can be larger than the default buffer
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var record HistoryRecord
+		if jsonErr := json.Unmarshal(scanner.Bytes(), &record); jsonErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring malformed record %s:%d: %v\n",
+				path, lineNumber, jsonErr)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+// Parses a time limit given to the history action. It can be a date ("2006-01-02"),
+// a date and time (RFC3339 or "2006-01-02 15:04") or a duration relative to now
+// ("36h", "7d"). An empty string means no limit and returns a zero time.
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("not a valid date or duration: %s", s)
+}
+
+// Displays the lay and remove executions recorded for the given bricks, or for every
+// brick of the infra if none is given, between --since and --until.
+// The format can be a table (default) or JSON.
+func History(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+) (
+	statusCode int,
+	err error,
+) {
+	now := time.Now()
+	since, err := parseHistoryTime(conf.Since, now)
+	if err != nil {
+		return exstatuscode.INIT_ERROR, exinfra.ErrBadArg{Reason: "Error: --since: " + err.Error()}
+	}
+	until, err := parseHistoryTime(conf.Until, now)
+	if err != nil {
+		return exstatuscode.INIT_ERROR, exinfra.ErrBadArg{Reason: "Error: --until: " + err.Error()}
+	}
+
+	var rooms exinfra.Bricks
+	brickNames := make(map[string]bool)
+	if len(bricksToExecute) == 0 {
+		for _, b := range infra.Bricks {
+			if b.Room == b {
+				rooms = append(rooms, b)
+			}
+		}
+	} else {
+		for _, b := range bricksToExecute {
+			rooms = append(rooms, b.Room)
+			brickNames[b.Name] = true
+		}
+		rooms = exinfra.RemoveDuplicates(rooms)
+	}
+
+	var records []HistoryRecord
+	for _, room := range rooms {
+		var roomRecords []HistoryRecord
+		roomRecords, err = readHistory(infra, room)
+		if err != nil {
+			return exstatuscode.RUN_ERROR, fmt.Errorf("unable to read history of room %s: %v", room.Name, err)
+		}
+
+		for _, r := range roomRecords {
+			if len(brickNames) > 0 && !brickNames[r.Brick] {
+				continue
+			}
+			if (!since.IsZero() && r.Time.Before(since)) || (!until.IsZero() && r.Time.After(until)) {
+				continue
+			}
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	switch conf.Format {
+	case "all", "a":
+		displayHistory(records)
+	case "json":
+		if records == nil {
+			records = []HistoryRecord{}
+		}
+		var content []byte
+		content, err = json.MarshalIndent(records, "", "\t")
+		if err != nil {
+			return exstatuscode.RUN_ERROR, err
+		}
+		fmt.Println(string(content))
+	default:
+		statusCode = exstatuscode.INIT_ERROR
+		err = exinfra.ErrBadArg{Reason: fmt.Sprintf(
+			"Error: format not valid for history action: %s", conf.Format)}
+	}
+
+	return
+}
+
+func displayHistory(records []HistoryRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTION\tSTATUS\tBRICK\tUSER\tDURATION\tCOMMIT\tERROR")
+
+	for _, r := range records {
+		commit := r.Commit
+		if len(commit) > 8 {
+			commit = commit[:8]
+		}
+		status := r.Status
+		if r.OutputDiff != nil {
+			status += " (output changed)"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s@%s\t%s\t%s\t%s\n",
+			r.Time.Local().Format("2006-01-02 15:04:05"),
+			r.Action,
+			status,
+			r.Brick,
+			r.User, r.Host,
+			(time.Duration(r.DurationMs) * time.Millisecond).String(),
+			commit,
+			strings.ReplaceAll(r.Error, "\n", " "))
+	}
+
+	w.Flush()
+}
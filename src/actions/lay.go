@@ -3,12 +3,19 @@ package actions
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
 	extools "src/exeiac/tools"
+	"strings"
+	"time"
 )
 
+// The reason given to the conditional bricks skipped by lay
+const REASON_NO_CONSUMED_CHANGE = "none of the values it consumes has changed"
+
 func Lay(
 	infra *exinfra.Infra,
 	conf *exargs.Configuration,
@@ -17,19 +24,66 @@ func Lay(
 	statusCode int,
 	err error,
 ) {
-	if len(bricksToExecute) == 0 {
-		err = exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for lay action"}
+	redirectDisplay(conf)
+
+	// the bricks to lay and the values consumed by the conditional ones before laying,
+	// restored from the last lay when resuming it
+	var state layState
+	var conditionalBricks exinfra.Bricks
+
+	if conf.Resume {
+		if len(bricksToExecute) > 0 {
+			err = exinfra.ErrBadArg{Reason: "Error: --resume lays the bricks of the last lay, " +
+				"no brick should be specified"}
+
+			return exstatuscode.INIT_ERROR, err
+		}
+
+		state, err = loadLayState(conf)
+		if err != nil {
+			return exstatuscode.INIT_ERROR, err
+		}
+		bricksToExecute, conditionalBricks, err = state.bricksToResume(infra)
+		if err != nil {
+			return exstatuscode.INIT_ERROR, err
+		}
+		if len(bricksToExecute) == 0 {
+			fmt.Println("Nothing to resume: the last lay has succeeded")
+
+			return 0, nil
+		}
+	} else {
+		if len(bricksToExecute) == 0 {
+			err = exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for lay action"}
 
+			return exstatuscode.INIT_ERROR, err
+		}
+
+		conditionalBricks, err = getConditionalBricks(infra, conf, bricksToExecute)
+		if err != nil {
+			return exstatuscode.INIT_ERROR, err
+		}
+	}
+
+	if conf.DryRun {
+		return dryRun(infra, conf, "lay", bricksToExecute, conditionalBricks, false, true)
+	}
+
+	if err = checkParallelism(conf); err != nil {
 		return exstatuscode.INIT_ERROR, err
 	}
 
 	if conf.Interactive {
 		fmt.Println("Here, the bricks list to lay :")
 		fmt.Print(bricksToExecute)
+		if len(conditionalBricks) > 0 {
+			fmt.Println("\nOnly if a value they consume has changed :")
+			fmt.Print(conditionalBricks)
+		}
 
 		// NOTE(half-shell): We might change this behavior to only ask for a "\n" input
 		// instead of a Y/N choice.
-		confirm, err := extools.AskConfirmation("\nDo you want to continue ?")
+		confirm, err := exinfra.AskConfirmation("\nDo you want to continue ?")
 
 		if err != nil {
 			return exstatuscode.RUN_ERROR, err
@@ -38,51 +92,96 @@ func Lay(
 		}
 	}
 
-	err = enrichDatas(bricksToExecute, infra)
+	err = enrichDatas(bricksToExecute, infra, conf)
 	if err != nil {
 		return exstatuscode.ENRICH_ERROR, err
 	}
 
-	skipFollowing := false
-	execSummary := make(ExecSummary, len(bricksToExecute))
+	// NOTE(half-shell): outputs are updated while bricks are laid, so we keep the hashes of
+	// the values consumed by the conditional bricks to know which ones have changed
+	if !conf.Resume {
+		state = newLayState(conf, bricksToExecute, conditionalBricks)
+	}
 
-	for i, b := range bricksToExecute {
-		extools.DisplaySeparator(b.Name)
-		report := ExecReport{Brick: b}
+	execSummary, statusCode := executeBricks(infra, bricksToExecute, "lay", conf.Parallelism, false,
+		conf.KeepGoing, false, func(b *exinfra.Brick, stdout io.Writer, stderr io.Writer) (
+			report ExecReport, statusCode int, skipFollowing bool,
+		) {
+			report = ExecReport{Brick: b, ExitCode: -1}
 
-		// skip if an error was encounter before
-		if skipFollowing {
-			report.Status = TAG_SKIP
-			execSummary[i] = report
-			fmt.Printf("lay skipped\n\n")
-			continue
-		}
+			if conditionalBricks.BricksContains(b) {
+				changedInputs, err := getChangedInputs(b, state.consumedHashes(b))
+				if err != nil {
+					report.Error = fmt.Errorf("not able to know if lay is needed: %v", err)
+					report.Status = TAG_ERROR
 
-		// write env file if needed
-		envs, err := writeEnvFilesAndGetEnvs(b)
-		if err != nil {
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
-			report.Error = fmt.Errorf("not able to get env file and vars before execute: %v", err)
-			report.Status = TAG_ERROR
-			continue
-		}
+					return report, exstatuscode.RUN_ERROR, true
+				}
+				if len(changedInputs) == 0 {
+					report.Status = TAG_SKIP
+					report.Reason = REASON_NO_CONSUMED_CHANGE
 
-		layExitStatus, layErr := b.Module.Exec(b, "lay", conf.OtherOptions, envs)
-		stdout := exinfra.StoreStdout{}
-		outputExitStatus, outputErr := b.Module.Exec(b, "output", []string{}, envs, &stdout)
+					return
+				}
+				for _, i := range changedInputs {
+					fmt.Fprintf(stdout, "lay needed: %s has changed\n", i.Origin())
+				}
+			}
+
+			start := time.Now()
+			outputBefore := b.Output
+			var envs []string
+			defer func() {
+				report.OutputChanged = !bytes.Equal(outputBefore, b.Output)
+				if report.OutputChanged {
+					reportOutputChanges(infra, b, outputBefore, &report, stdout)
+				}
+				recordHistory(infra, b, "lay", start, envs, outputBefore, report, stderr)
+			}()
 
-		// set skipFollowing, report.Status, report.Error and update b.Ouput
-		if layErr == nil && layExitStatus == 0 && outputErr == nil && outputExitStatus == 0 { // everything runs well
-			if bytes.Compare(stdout.Output, b.Output) == 0 {
-				report.Status = TAG_NO_CHANGE
-			} else {
-				b.Output = stdout.Output
-				report.Status = TAG_DONE
+			// write env file if needed
+			envs, err := writeEnvFilesAndGetEnvs(b)
+			if err != nil {
+				report.Error = fmt.Errorf("not able to get env file and vars before execute: %v", err)
+				report.Status = TAG_ERROR
+
+				return report, exstatuscode.RUN_ERROR, false
 			}
-		} else { // there is at least one error
+
+			layExitStatus, layErr := b.Module.Exec(b, "lay", conf.OtherOptions, envs, stdout, stderr)
+			report.ExitCode = layExitStatus
+			output := exinfra.StoreStdout{}
+			outputExitStatus, outputErr := b.Module.Exec(b, "output", []string{}, envs, &output, stderr)
+
+			// the cached output is outdated whatever happens
+			defer func() {
+				var cacheErr error
+				if outputErr == nil && outputExitStatus == 0 && bytes.Equal(output.Output, b.Output) {
+					cacheErr = infra.CacheOutput(b)
+				} else {
+					cacheErr = infra.InvalidateCachedOutput(b)
+				}
+				if cacheErr != nil {
+					fmt.Fprintf(stderr, "Warning: unable to update cached output of %s: %v\n", b.Name, cacheErr)
+				}
+			}()
+
+			// set skipFollowing, report.Status, report.Error and update b.Ouput
+			if layErr == nil && layExitStatus == 0 && outputErr == nil && outputExitStatus == 0 { // everything runs well
+				if bytes.Compare(output.Output, b.Output) == 0 {
+					report.Status = TAG_NO_CHANGE
+				} else {
+					b.Output = output.Output
+					report.Status = TAG_DONE
+				}
+
+				return
+			}
+
+			// there is at least one error
 			skipFollowing = true
 			report.Status = TAG_ERROR
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
+			statusCode = exstatuscode.MODULE_ERROR
 
 			// simplify the next condition tree
 			if layExitStatus != 0 && layErr == nil {
@@ -96,7 +195,7 @@ func Lay(
 				report.Error = fmt.Errorf("2 errors lay and output error: "+
 					"{\"lay\": \"%v\", \"output\": \"%v\"}", layErr, outputErr)
 			} else if layErr != nil && outputExitStatus == 0 { // 1 error: check if output changed
-				if bytes.Compare(stdout.Output, b.Output) == 0 {
+				if bytes.Compare(output.Output, b.Output) == 0 {
 					report.Error = fmt.Errorf(
 						"lay has failed, output doesn't seem to has changed: %v",
 						layErr)
@@ -104,20 +203,138 @@ func Lay(
 					report.Error = fmt.Errorf(
 						"lay has failed, output has changed: %v",
 						layErr)
-					b.Output = stdout.Output
+					b.Output = output.Output
 				}
 			} else if layExitStatus == 0 && outputErr != nil { // 1 error: can't get output
 				report.Error = fmt.Errorf(
 					"lay seems to success but the following output failed: %v",
 					outputErr)
 			}
+
+			return
+		})
+
+	state.update(execSummary)
+	if stateErr := state.save(conf); stateErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: unable to save the lay state to resume it: %v\n", stateErr)
+	}
+
+	return execSummary.Report(conf, statusCode)
+}
+
+// Returns the bricks to lay only if a value they consume has changed, i.e. the ones that are
+// only selected by a needed_next specifier.
+func getConditionalBricks(
+	infra *exinfra.Infra,
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+) (
+	conditionalBricks exinfra.Bricks,
+	err error,
+) {
+	var otherSpecifiers []string
+	for _, specifier := range conf.BricksSpecifiers {
+		if !extools.ContainsString(exargs.NeededNextSpecifiers[:], specifier) {
+			otherSpecifiers = append(otherSpecifiers, specifier)
 		}
+	}
+	if len(otherSpecifiers) == len(conf.BricksSpecifiers) {
+		return
+	}
 
-		execSummary[i] = report
-		fmt.Println("")
+	selectedBricks, err := infra.GetBricksFromNames(conf.BricksNames)
+	if err != nil {
+		return
 	}
+	unconditionalBricks, err := infra.GetCorrespondingBricks(selectedBricks, otherSpecifiers)
+	if err != nil {
+		return
+	}
+
+	for _, b := range bricksToExecute {
+		if !unconditionalBricks.BricksContains(b) {
+			conditionalBricks = append(conditionalBricks, b)
+		}
+	}
+
+	return
+}
+
+// Returns the inputs of a brick whose value has changed, given the hashes of the values it
+// consumed before by input origin. A value without hash is seen as changed.
+func getChangedInputs(
+	b *exinfra.Brick,
+	hashesBefore map[string]string,
+) (
+	changedInputs []exinfra.Input,
+	err error,
+) {
+	for _, i := range b.Inputs {
+		if i.Brick == nil {
+			continue
+		}
 
-	execSummary.Display()
+		hash, err := i.ConsumedValueHash(exinfra.CurrentOutput)
+		if err != nil {
+			return nil, err
+		}
+		if hashBefore, exist := hashesBefore[i.Origin()]; !exist || hashBefore != hash {
+			changedInputs = append(changedInputs, i)
+		}
+	}
 
 	return
 }
+
+// Computes the changes of a laid brick's output and adds them to the report, along with the
+// direct next bricks consuming a changed value. Displays them in `stdout`.
+func reportOutputChanges(
+	infra *exinfra.Infra,
+	b *exinfra.Brick,
+	outputBefore []byte,
+	report *ExecReport,
+	stdout io.Writer,
+) {
+	changes, err := exinfra.DiffOutputs(outputBefore, b.Output)
+	if err != nil {
+		fmt.Fprintf(stdout, "Output has changed but can't be compared: %v\n", err)
+
+		return
+	}
+	report.OutputDiff = changes
+
+	fmt.Fprintln(stdout, "Output changes:")
+	for _, c := range changes {
+		fmt.Fprintf(stdout, "  %s\n", c)
+	}
+
+	// NOTE(half-shell): an input can come from a super-brick containing the laid brick
+	providers := append(infra.GetSuperBricks(b), b)
+	previousOutputOf := func(previous *exinfra.Brick) []byte {
+		if previous == b {
+			return outputBefore
+		}
+
+		return previous.Output
+	}
+	nextBricks, _ := infra.GetDirectNext(b)
+	for _, next := range nextBricks {
+		var varNames []string
+		for _, i := range next.Inputs {
+			if i.Brick == nil || !providers.BricksContains(i.Brick) {
+				continue
+			}
+			if changed, _ := i.HasChanged(previousOutputOf); changed {
+				varNames = append(varNames, i.VarName)
+			}
+		}
+
+		if len(varNames) > 0 {
+			if len(report.ImpactedBricks) == 0 {
+				fmt.Fprintln(stdout, "Consumed by:")
+			}
+			report.ImpactedBricks = append(report.ImpactedBricks, next)
+			fmt.Fprintf(stdout, "  %s (%s)\n", next.Name, strings.Join(extools.Deduplicate(varNames), ", "))
+		}
+	}
+}
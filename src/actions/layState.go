@@ -0,0 +1,180 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+
+	"github.com/adrg/xdg"
+)
+
+// The directory, relative to the XDG state directory, where the last lay of each infra is
+// stored to be resumed
+const LAY_STATE_DIR = "exeiac/lays"
+
+type layStateBrick struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Wheither the brick is only laid if a value it consumes has changed
+	Conditional bool `json:"conditional,omitempty"`
+	// The hashes of the values a conditional brick consumed before the first lay started, by
+	// input origin. Values aren't stored since outputs can contain secrets.
+	ConsumedHashes map[string]string `json:"consumed_hashes,omitempty"`
+}
+
+// The bricks of the last lay and their results, allowing to resume it
+type layState struct {
+	Time             time.Time       `json:"time"`
+	BricksNames      []string        `json:"bricks_names"`
+	BricksSpecifiers []string        `json:"bricks_specifiers"`
+	Bricks           []layStateBrick `json:"bricks"`
+}
+
+// Returns the file where the last lay is stored. An infra is identified by exeiac's
+// configuration file and its rooms, so that each one has its own last lay.
+func layStateFilePath(conf *exargs.Configuration) (string, error) {
+	var names []string
+	for name := range conf.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "configuration:%s\n", conf.ConfigurationFile)
+	for _, name := range names {
+		fmt.Fprintf(hash, "room:%s:%s\n", name, conf.Rooms[name])
+	}
+
+	return xdg.StateFile(filepath.Join(LAY_STATE_DIR, hex.EncodeToString(hash.Sum(nil))+".json"))
+}
+
+func loadLayState(conf *exargs.Configuration) (state layState, err error) {
+	path, err := layStateFilePath(conf)
+	if err != nil {
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, fmt.Errorf("there is no lay to resume")
+	} else if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		err = fmt.Errorf("unable to read the last lay state %s: %v", path, err)
+	}
+
+	return
+}
+
+func (state layState) save(conf *exargs.Configuration) error {
+	path, err := layStateFilePath(conf)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
+
+// Builds the state of a lay that is about to start, once the outputs of the bricks are known.
+func newLayState(
+	conf *exargs.Configuration,
+	bricksToExecute exinfra.Bricks,
+	conditionalBricks exinfra.Bricks,
+) layState {
+	state := layState{
+		Time:             time.Now(),
+		BricksNames:      conf.BricksNames,
+		BricksSpecifiers: conf.BricksSpecifiers,
+	}
+
+	for _, b := range bricksToExecute {
+		stateBrick := layStateBrick{Name: b.Name}
+		if conditionalBricks.BricksContains(b) {
+			stateBrick.Conditional = true
+			stateBrick.ConsumedHashes = make(map[string]string)
+			for _, i := range b.Inputs {
+				if i.Brick == nil {
+					continue
+				}
+				// NOTE(half-shell): a value that can't be read is seen as changed afterward
+				if hash, err := i.ConsumedValueHash(exinfra.CurrentOutput); err == nil {
+					stateBrick.ConsumedHashes[i.Origin()] = hash
+				}
+			}
+		}
+		state.Bricks = append(state.Bricks, stateBrick)
+	}
+
+	return state
+}
+
+// Returns the hashes of the values a conditional brick consumed before the first lay started
+func (state layState) consumedHashes(b *exinfra.Brick) map[string]string {
+	for _, stateBrick := range state.Bricks {
+		if stateBrick.Name == b.Name {
+			return stateBrick.ConsumedHashes
+		}
+	}
+
+	return nil
+}
+
+// Updates the state with the results of a lay
+func (state *layState) update(execSummary ExecSummary) {
+	results := make(map[string]ExecReport)
+	for _, report := range execSummary {
+		results[report.Brick.Name] = report
+	}
+
+	for i, b := range state.Bricks {
+		if report, exist := results[b.Name]; exist {
+			state.Bricks[i].Status = report.Status
+			state.Bricks[i].Reason = report.Reason
+		}
+	}
+}
+
+// Returns the bricks of the last lay that have to be laid again: the ones that failed or have
+// been interrupted, and the ones skipped because of a failure or an interruption. Returns also the ones among them that are conditional.
+func (state layState) bricksToResume(
+	infra *exinfra.Infra,
+) (
+	bricks exinfra.Bricks,
+	conditionalBricks exinfra.Bricks,
+	err error,
+) {
+	for _, b := range state.Bricks {
+		if b.Status != TAG_ERROR && b.Status != TAG_INTERRUPTED && !(b.Status == TAG_SKIP && b.Reason != REASON_NO_CONSUMED_CHANGE) {
+			continue
+		}
+
+		brick, exist := infra.Bricks[b.Name]
+		if !exist {
+			return nil, nil, fmt.Errorf("brick %s of the last lay doesn't exist anymore", b.Name)
+		}
+		bricks = append(bricks, brick)
+		if b.Conditional {
+			conditionalBricks = append(conditionalBricks, brick)
+		}
+	}
+
+	return
+}
@@ -2,10 +2,10 @@ package actions
 
 import (
 	"fmt"
+	"io"
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
-	extools "src/exeiac/tools"
 )
 
 func Plan(
@@ -16,57 +16,72 @@ func Plan(
 	statusCode int,
 	err error,
 ) {
+	redirectDisplay(conf)
+
 	if len(bricksToExecute) == 0 {
 		err = exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for plan action"}
 
 		return exstatuscode.INIT_ERROR, err
 	}
 
-	err = enrichDatas(bricksToExecute, infra)
+	if conf.DryRun {
+		return dryRun(infra, conf, "plan", bricksToExecute, nil, false, true)
+	}
+
+	if err = checkParallelism(conf); err != nil {
+		return exstatuscode.INIT_ERROR, err
+	}
+
+	err = enrichDatas(bricksToExecute, infra, conf)
 	if err != nil {
 		return exstatuscode.ENRICH_ERROR, err
 	}
 
-	execSummary := make(ExecSummary, len(bricksToExecute))
+	execSummary, statusCode := executeBricks(infra, bricksToExecute, "plan", conf.Parallelism, false,
+		conf.KeepGoing, false, planTask(conf))
 
-	for i, b := range bricksToExecute {
-		extools.DisplaySeparator(b.Name)
-		report := ExecReport{Brick: b}
+	return execSummary.Report(conf, statusCode)
+}
+
+// Returns the task planning a brick, and classifying it from the plan's exit status.
+func planTask(conf *exargs.Configuration) brickTask {
+	return func(b *exinfra.Brick, stdout io.Writer, stderr io.Writer) (
+		report ExecReport, statusCode int, skipFollowing bool,
+	) {
+		report = ExecReport{Brick: b, ExitCode: -1}
 
 		// write env file if needed
 		envs, err := writeEnvFilesAndGetEnvs(b)
 		if err != nil {
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
 			report.Error = fmt.Errorf("not able to get env file and vars before execute: %v", err)
 			report.Status = TAG_ERROR
-			continue
+
+			return report, exstatuscode.RUN_ERROR, false
 		}
 
-		// lay and manage error
-		exitStatus, err := b.Module.Exec(b, "plan", conf.OtherOptions, envs)
+		// plan and manage error
+		captured, stdout, stderr := captureOutputs(conf, stdout, stderr)
+		exitStatus, err := b.Module.Exec(b, "plan", conf.OtherOptions, envs, stdout, stderr)
+		captured.fill(&report)
+		report.ExitCode = exitStatus
 		if err != nil {
 			report.Error = err
 			report.Status = TAG_ERROR
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
+			statusCode = exstatuscode.MODULE_ERROR
 		} else if exitStatus == 0 {
 			report.Status = TAG_NO_CHANGE
 		} else if exitStatus == 2 {
 			report.Status = TAG_DRIFT
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_DRIFT)
+			statusCode = exstatuscode.MODULE_DRIFT
 		} else if exitStatus == 3 {
 			report.Status = TAG_MAY_DRIFT
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_DRIFT_OR_NOT)
+			statusCode = exstatuscode.MODULE_DRIFT_OR_NOT
 		} else {
 			report.Error = fmt.Errorf("plan return: %d", exitStatus)
 			report.Status = TAG_ERROR
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
+			statusCode = exstatuscode.MODULE_ERROR
 		}
 
-		execSummary[i] = report
-		fmt.Println("")
+		return
 	}
-
-	execSummary.Display()
-
-	return
 }
@@ -2,11 +2,12 @@ package actions
 
 import (
 	"fmt"
+	"io"
 	"sort"
 	exargs "src/exeiac/arguments"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
-	extools "src/exeiac/tools"
+	"time"
 )
 
 func Remove(
@@ -17,19 +18,31 @@ func Remove(
 	statusCode int,
 	err error,
 ) {
+	redirectDisplay(conf)
+
 	if len(bricksToExecute) == 0 {
 		err = exinfra.ErrBadArg{Reason: "Error: you should specify at least a brick for \"remove\" action"}
 
 		return exstatuscode.INIT_ERROR, err
 	}
 
+	sort.Sort(sort.Reverse(bricksToExecute))
+
+	if conf.DryRun {
+		return dryRun(infra, conf, "remove", bricksToExecute, nil, true, true)
+	}
+
+	if err = checkParallelism(conf); err != nil {
+		return exstatuscode.INIT_ERROR, err
+	}
+
 	if conf.Interactive {
 		fmt.Println("Here, the bricks list to remove :")
 		fmt.Print(bricksToExecute)
 
 		// NOTE(half-shell): We might change this behavior to only ask for a "\n" input
 		// instead of a Y/N choice.
-		confirm, err := extools.AskConfirmation("\nDo you want to continue ?")
+		confirm, err := exinfra.AskConfirmation("\nDo you want to continue ?")
 
 		if err != nil {
 			return exstatuscode.RUN_ERROR, err
@@ -38,59 +51,58 @@ func Remove(
 		}
 	}
 
-	err = enrichDatas(bricksToExecute, infra)
+	err = enrichDatas(bricksToExecute, infra, conf)
 	if err != nil {
 		return exstatuscode.ENRICH_ERROR, err
 	}
 
-	skipFollowing := false
-	execSummary := make(ExecSummary, len(bricksToExecute))
-
-	sort.Sort(sort.Reverse(bricksToExecute))
-
-	for i, b := range bricksToExecute {
-
-		extools.DisplaySeparator(b.Name)
-		report := ExecReport{Brick: b}
-
-		// skip if an error was encounter before
-		if skipFollowing {
-			report.Status = "SKIP"
-			execSummary[i] = report
-			fmt.Printf("remove skipped\n\n")
-			continue
-		}
-
-		// write env file if needed
-		var envs []string
-		envs, err = writeEnvFilesAndGetEnvs(b)
-		if err != nil {
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
-			report.Error = fmt.Errorf("not able to get env file and vars before execute: %v", err)
-			report.Status = TAG_ERROR
-			continue
-		}
-
-		// lay and manage error
-		exitStatus, err := b.Module.Exec(b, "remove", conf.OtherOptions, envs)
-		if err != nil {
-			skipFollowing = true
-			report.Error = err
-			report.Status = TAG_ERROR
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
-		} else if exitStatus != 0 {
-			skipFollowing = true
-			report.Error = fmt.Errorf("remove return: %d", exitStatus)
-			report.Status = TAG_ERROR
-			statusCode = exstatuscode.Update(statusCode, exstatuscode.MODULE_ERROR)
-		} else {
-			report.Status = TAG_OK
-		}
-
-		execSummary[i] = report
-		fmt.Println("")
-	}
-
-	execSummary.Display()
-	return
+	execSummary, statusCode := executeBricks(infra, bricksToExecute, "remove", conf.Parallelism, true,
+		conf.KeepGoing, false, func(b *exinfra.Brick, stdout io.Writer, stderr io.Writer) (
+			report ExecReport, statusCode int, skipFollowing bool,
+		) {
+			report = ExecReport{Brick: b, ExitCode: -1}
+
+			start := time.Now()
+			outputBefore := b.Output
+			var envs []string
+			defer func() {
+				recordHistory(infra, b, "remove", start, envs, outputBefore, report, stderr)
+			}()
+
+			// write env file if needed
+			envs, err := writeEnvFilesAndGetEnvs(b)
+			if err != nil {
+				report.Error = fmt.Errorf("not able to get env file and vars before execute: %v", err)
+				report.Status = TAG_ERROR
+
+				return report, exstatuscode.RUN_ERROR, false
+			}
+
+			// remove and manage error
+			exitStatus, err := b.Module.Exec(b, "remove", conf.OtherOptions, envs, stdout, stderr)
+			report.ExitCode = exitStatus
+
+			// the cached output is outdated whatever happens
+			if cacheErr := infra.InvalidateCachedOutput(b); cacheErr != nil {
+				fmt.Fprintf(stderr, "Warning: unable to invalidate cached output of %s: %v\n", b.Name, cacheErr)
+			}
+
+			if err != nil {
+				skipFollowing = true
+				report.Error = err
+				report.Status = TAG_ERROR
+				statusCode = exstatuscode.MODULE_ERROR
+			} else if exitStatus != 0 {
+				skipFollowing = true
+				report.Error = fmt.Errorf("remove return: %d", exitStatus)
+				report.Status = TAG_ERROR
+				statusCode = exstatuscode.MODULE_ERROR
+			} else {
+				report.Status = TAG_OK
+			}
+
+			return
+		})
+
+	return execSummary.Report(conf, statusCode)
 }
@@ -0,0 +1,320 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+)
+
+type jsonBrickReport struct {
+	Name          string   `json:"name"`
+	Path          string   `json:"path"`
+	Module        string   `json:"module"`
+	Action        string   `json:"action"`
+	Status        string   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	Reason        string   `json:"reason,omitempty"`
+	ExitCode      int      `json:"exit_code"`
+	Duration      float64  `json:"duration"`
+	OutputChanged bool     `json:"output_changed"`
+	LogFile       string   `json:"log_file,omitempty"`
+	StderrTail    []string `json:"stderr_tail,omitempty"`
+	// The changes of the output, and the bricks consuming a changed value
+	OutputDiff     []exinfra.OutputChange `json:"output_diff,omitempty"`
+	ImpactedBricks []string               `json:"impacted_bricks,omitempty"`
+}
+
+type jsonReport struct {
+	Action     string            `json:"action"`
+	StatusCode int               `json:"status_code"`
+	Bricks     []jsonBrickReport `json:"bricks"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",cdata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+type junitOutput struct {
+	Content string `xml:",cdata"`
+}
+
+// Returns nil for an empty output, so that it is omitted
+func newJunitOutput(output []byte) *junitOutput {
+	if len(output) == 0 {
+		return nil
+	}
+
+	return &junitOutput{Content: string(output)}
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+	SystemErr *junitOutput  `xml:"system-err,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitReport struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// Where a json or junit report is written without a report file, see redirectDisplay
+var reportOutput io.Writer = os.Stdout
+
+// Moves what an action displays (bricks separators, modules outputs, questions...) to the
+// standard error if a json or junit report is written on the standard output, so that the
+// report can be parsed. Has to be called once, before the action displays anything.
+func redirectDisplay(conf *exargs.Configuration) {
+	if conf.ReportFile != "" || (conf.ReportFormat != "json" && conf.ReportFormat != "junit") {
+		return
+	}
+
+	reportOutput, os.Stdout = os.Stdout, os.Stderr
+}
+
+// Matches the ANSI escape sequences used by modules to colorize their outputs
+var ansiEscapeRegexp = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+// The module's outputs of a brick execution, captured for the report
+type capturedOutputs struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+}
+
+// Returns writers writing both in `stdout` and `stderr` and in the returned capturedOutputs
+// if the report format needs the modules outputs.
+// Otherwise returns nil and the given writers.
+func captureOutputs(
+	conf *exargs.Configuration,
+	stdout io.Writer,
+	stderr io.Writer,
+) (
+	*capturedOutputs,
+	io.Writer,
+	io.Writer,
+) {
+	if conf.ReportFormat != "junit" {
+		return nil, stdout, stderr
+	}
+
+	c := &capturedOutputs{}
+
+	return c, io.MultiWriter(stdout, &c.stdout), io.MultiWriter(stderr, &c.stderr)
+}
+
+// Sets the report's Stdout and Stderr with the captured outputs, if any.
+func (c *capturedOutputs) fill(report *ExecReport) {
+	if c == nil {
+		return
+	}
+
+	report.Stdout = ansiEscapeRegexp.ReplaceAll(c.stdout.Bytes(), nil)
+	report.Stderr = ansiEscapeRegexp.ReplaceAll(c.stderr.Bytes(), nil)
+}
+
+func moduleName(b *exinfra.Brick) string {
+	if b.Module == nil {
+		return ""
+	}
+
+	return b.Module.Name
+}
+
+func (es ExecSummary) jsonReport(action string, statusCode int) ([]byte, error) {
+	report := jsonReport{
+		Action:     action,
+		StatusCode: statusCode,
+		Bricks:     []jsonBrickReport{},
+	}
+
+	for _, r := range es {
+		brickReport := jsonBrickReport{
+			Name:          r.Brick.Name,
+			Path:          r.Brick.Path,
+			Module:        moduleName(r.Brick),
+			Action:        r.Action,
+			Status:        r.Status,
+			Reason:        r.Reason,
+			ExitCode:      r.ExitCode,
+			Duration:      r.Duration.Seconds(),
+			OutputChanged: r.OutputChanged,
+			LogFile:       r.LogFile,
+			StderrTail:    r.StderrTail,
+			OutputDiff:    r.OutputDiff,
+		}
+		for _, impacted := range r.ImpactedBricks {
+			brickReport.ImpactedBricks = append(brickReport.ImpactedBricks, impacted.Name)
+		}
+		if r.Error != nil {
+			brickReport.Error = r.Error.Error()
+		}
+		report.Bricks = append(report.Bricks, brickReport)
+	}
+
+	return json.MarshalIndent(report, "", "\t")
+}
+
+// Builds a JUnit report with a test suite per room and a test case per brick.
+// Errors are reported as JUnit errors along with the module's stderr, drifts as failures
+// along with the module's stdout (i.e. the plan), and skipped bricks as skipped.
+func (es ExecSummary) junitReport(action string) ([]byte, error) {
+	report := junitReport{Name: "exeiac " + action}
+	suites := make(map[*exinfra.Brick]int)
+
+	for _, r := range es {
+		i, exist := suites[r.Brick.Room]
+		if !exist {
+			i = len(report.TestSuites)
+			suites[r.Brick.Room] = i
+			report.TestSuites = append(report.TestSuites, junitTestSuite{Name: r.Brick.Room.Name})
+		}
+		suite := &report.TestSuites[i]
+
+		testCase := junitTestCase{
+			Name:      r.Brick.Name,
+			ClassName: fmt.Sprintf("%s.%s", action, r.Brick.Room.Name),
+			Time:      fmt.Sprintf("%.3f", r.Duration.Seconds()),
+			SystemOut: newJunitOutput(r.Stdout),
+			SystemErr: newJunitOutput(r.Stderr),
+		}
+		switch r.Status {
+		case TAG_ERROR, TAG_INTERRUPTED, "":
+			message := "no status"
+			if r.Error != nil {
+				message = r.Error.Error()
+			}
+			testCase.Error = &junitProblem{Message: message, Type: r.Status, Content: string(r.Stderr)}
+			if r.Status == "" {
+				testCase.Error.Type = TAG_ERROR
+			}
+			suite.Errors++
+		case TAG_DRIFT, TAG_MAY_DRIFT:
+			testCase.Failure = &junitProblem{
+				Message: fmt.Sprintf("%s has drifted", r.Brick.Name),
+				Type:    r.Status,
+				Content: string(r.Stdout),
+			}
+			if r.Status == TAG_MAY_DRIFT {
+				testCase.Failure.Message = fmt.Sprintf("%s may have drifted", r.Brick.Name)
+			}
+			suite.Failures++
+		case TAG_SKIP:
+			testCase.Skipped = &junitSkipped{Message: r.Reason}
+			suite.Skipped++
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for i, suite := range report.TestSuites {
+		var duration float64
+		for _, r := range es {
+			if r.Brick.Room.Name == suite.Name {
+				duration += r.Duration.Seconds()
+			}
+		}
+		report.TestSuites[i].Time = fmt.Sprintf("%.3f", duration)
+	}
+
+	content, err := xml.MarshalIndent(report, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), append(content, '\n')...), nil
+}
+
+// Displays the summary of the execution and writes the report asked with --report-format
+// and --report-file.
+// Without a report file, a json or junit report replaces the summary on the standard output,
+// everything else being displayed on the standard error (see redirectDisplay).
+// Returns the status code updated with a RUN_ERROR if the report couldn't be written.
+func (es ExecSummary) Report(
+	conf *exargs.Configuration,
+	statusCode int,
+) (
+	int,
+	error,
+) {
+	return es.report(conf, statusCode, es.Display)
+}
+
+// Same as Report, but the summary is displayed by `display`.
+func (es ExecSummary) report(
+	conf *exargs.Configuration,
+	statusCode int,
+	display func(),
+) (
+	int,
+	error,
+) {
+	var content []byte
+	var err error
+
+	switch conf.ReportFormat {
+	case "", "text":
+		if conf.ReportFile == "" {
+			display()
+
+			return statusCode, nil
+		}
+		content = []byte(es.summary(false) + fmt.Sprintf("Status code: %d\n", statusCode))
+	case "json":
+		content, err = es.jsonReport(conf.Action, statusCode)
+		content = append(content, '\n')
+	case "junit":
+		content, err = es.junitReport(conf.Action)
+	default:
+		err = exinfra.ErrBadArg{Reason: "Error: report format not valid", Value: conf.ReportFormat}
+	}
+	if err != nil {
+		return exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR), err
+	}
+
+	if conf.ReportFile == "" {
+		// NOTE(half-shell): the summary would mess up a report written on the standard output
+		_, err = reportOutput.Write(content)
+	} else {
+		display()
+		err = os.WriteFile(conf.ReportFile, content, 0666)
+	}
+	if err != nil {
+		err = fmt.Errorf("unable to write the %s report: %v", conf.ReportFormat, err)
+
+		return exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR), err
+	}
+
+	return statusCode, nil
+}
@@ -0,0 +1,307 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+	extools "src/exeiac/tools"
+	"time"
+)
+
+// The reason given to the bricks skipped because a brick has failed
+const REASON_PREVIOUS_FAILED = "a previous brick has failed"
+
+// A function executing an action over a single brick for `executeBricks`.
+// Module's stdout and stderr have to be written in the provided writers, so that they can
+// be captured when several bricks run at the same time.
+// Returns the brick's report, the status code to merge in the overall one, and wheither or
+// not the bricks that haven't been started yet should be skipped. The reason of a report
+// skipping the brick is displayed by `executeBricks`.
+type brickTask func(
+	b *exinfra.Brick,
+	stdout io.Writer,
+	stderr io.Writer,
+) (
+	report ExecReport,
+	statusCode int,
+	skipFollowing bool,
+)
+
+// Returns an error if several bricks would be executed at the same time while modules can
+// ask for user input: their outputs being captured, their prompts wouldn't be displayed and
+// they would wait forever.
+func checkParallelism(conf *exargs.Configuration) error {
+	if conf.Parallelism > 1 && !extools.ContainsString(conf.OtherOptions, "--non-interactive") {
+		return exinfra.ErrBadArg{Reason: "Error: --parallelism greater than 1 requires --non-interactive"}
+	}
+
+	return nil
+}
+
+type brickTaskResult struct {
+	position      int
+	report        ExecReport
+	statusCode    int
+	skipFollowing bool
+	// modules outputs captured when bricks are executed in parallel
+	stdout []byte
+	stderr []byte
+}
+
+// Executes `task` over every brick of `bricks`, following the dependency graph:
+// a brick is started as soon as all of its direct previous bricks (among `bricks`) are done.
+// If `reverse` is true, the graph is walked backward: a brick waits for its direct next
+// bricks instead (e.g. for the remove action).
+// At most `parallelism` bricks are executed at the same time. With a parallelism of 1,
+// bricks are executed one after the other in the `bricks` order and modules outputs are
+// displayed as they come. Otherwise, they are captured and displayed once the brick is done.
+// If `quiet` is true, modules outputs aren't displayed and the bricks separators are
+// written in stderr. Modules outputs are written in a log file in any case.
+// When a task asks to skip the following bricks, no brick is started anymore unless
+// `keepGoing` is true. In this case, only the linked next bricks of the brick (or the linked
+// previous ones if `reverse` is true) are skipped.
+// Returns an ExecSummary ordered as `bricks` and the merged status code.
+func executeBricks(
+	infra *exinfra.Infra,
+	bricks exinfra.Bricks,
+	action string,
+	parallelism int,
+	reverse bool,
+	keepGoing bool,
+	quiet bool,
+	task brickTask,
+) (
+	execSummary ExecSummary,
+	statusCode int,
+) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var display, moduleStdout, moduleStderr io.Writer = os.Stdout, os.Stdout, os.Stderr
+	if quiet {
+		display, moduleStdout, moduleStderr = os.Stderr, io.Discard, io.Discard
+	}
+
+	positions := make(map[*exinfra.Brick]int)
+	for i, b := range bricks {
+		positions[b] = i
+	}
+
+	// waitingFor[i] is the number of bricks bricks[i] still waits for, and unlocks[i]
+	// the positions of the bricks waiting for bricks[i]
+	waitingFor := make([]int, len(bricks))
+	unlocks := make([][]int, len(bricks))
+	for i, b := range bricks {
+		var dependencies exinfra.Bricks
+		var err error
+		if reverse {
+			dependencies, err = infra.GetDirectNext(b)
+		} else {
+			dependencies, err = infra.GetDirectPrevious(b)
+		}
+		if err != nil && i > 0 {
+			// NOTE(half-shell): we can't trust the dependency graph here,
+			// so we fall back on the bricks ordering
+			dependencies = exinfra.Bricks{bricks[i-1]}
+		}
+
+		for _, d := range exinfra.RemoveDuplicates(dependencies) {
+			if j, isExecuted := positions[d]; isExecuted && j != i {
+				waitingFor[i]++
+				unlocks[j] = append(unlocks[j], i)
+			}
+		}
+	}
+
+	results := make(chan brickTaskResult)
+	run := func(position int) {
+		b := bricks[position]
+		result := brickTaskResult{position: position}
+		start := time.Now()
+		log := newBrickLog(b, action, os.Stderr)
+
+		// NOTE(half-shell): the reason of a brick skipped by its task isn't written in the
+		// log, since no module has been executed
+		displaySkipReason := func(stdout io.Writer) {
+			if result.report.Status == TAG_SKIP && result.report.Reason != "" {
+				fmt.Fprintf(stdout, "%s skipped: %s\n", action, result.report.Reason)
+			}
+		}
+
+		if parallelism == 1 {
+			extools.DisplaySeparatorTo(display, b.Name)
+			stdout, stderr := log.tee(moduleStdout, moduleStderr)
+			result.report, result.statusCode, result.skipFollowing = task(b, stdout, stderr)
+			displaySkipReason(moduleStdout)
+			fmt.Fprintln(display, "")
+		} else {
+			// NOTE(half-shell): outputs are displayed by the goroutine reading results
+			// to avoid mixing up the bricks outputs
+			var stdoutBuffer, stderrBuffer bytes.Buffer
+			stdout, stderr := log.tee(&stdoutBuffer, &stderrBuffer)
+			result.report, result.statusCode, result.skipFollowing = task(b, stdout, stderr)
+			displaySkipReason(&stdoutBuffer)
+			result.stdout = stdoutBuffer.Bytes()
+			result.stderr = stderrBuffer.Bytes()
+		}
+		log.close(&result.report)
+		result.report.Brick = b
+		if result.report.markInterrupted(os.Stderr) {
+			result.skipFollowing = true
+		}
+		result.report.Action = action
+		result.report.Duration = time.Since(start)
+		results <- result
+	}
+
+	var ready []int
+	for i := range bricks {
+		if waitingFor[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	// skipReasons[i] explains why bricks[i] won't be started, because of a failed brick
+	skipReasons := make([]string, len(bricks))
+	skipLinkedBricks := func(position int) {
+		failed := bricks[position]
+		var linked exinfra.Bricks
+		var reason string
+		if reverse {
+			linked, _ = infra.GetLinkedPrevious(failed)
+			reason = fmt.Sprintf("%s, depending on it, has failed", failed.Name)
+		} else {
+			linked, _ = infra.GetLinkedNext(failed)
+			reason = fmt.Sprintf("it depends on %s that has failed", failed.Name)
+		}
+
+		// NOTE(half-shell): the bricks waiting for the failed one are added in case the
+		// dependency graph isn't valid (see above)
+		toVisit := append([]int{}, unlocks[position]...)
+		for _, l := range linked {
+			if i, isExecuted := positions[l]; isExecuted {
+				toVisit = append(toVisit, i)
+			}
+		}
+		for len(toVisit) > 0 {
+			i := toVisit[0]
+			toVisit = toVisit[1:]
+			if skipReasons[i] != "" || execSummary[i].Brick != nil {
+				continue
+			}
+			skipReasons[i] = reason
+			toVisit = append(toVisit, unlocks[i]...)
+		}
+
+		var stillReady []int
+		for _, i := range ready {
+			if skipReasons[i] == "" {
+				stillReady = append(stillReady, i)
+			}
+		}
+		ready = stillReady
+	}
+
+	execSummary = make(ExecSummary, len(bricks))
+	running := 0
+	skipFollowing := false
+	for {
+		for !skipFollowing && !exinfra.IsInterrupted() && running < parallelism && len(ready) > 0 {
+			go run(ready[0])
+			ready = ready[1:]
+			running++
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		if parallelism > 1 {
+			extools.DisplaySeparatorTo(display, result.report.Brick.Name)
+			moduleStdout.Write(result.stdout)
+			moduleStderr.Write(result.stderr)
+			fmt.Fprintln(display, "")
+		}
+
+		execSummary[result.position] = result.report
+		statusCode = exstatuscode.Update(statusCode, result.statusCode)
+		if exinfra.IsInterrupted() {
+			skipFollowing = true
+		} else if result.skipFollowing && keepGoing {
+			skipLinkedBricks(result.position)
+		} else {
+			skipFollowing = skipFollowing || result.skipFollowing
+		}
+
+		for _, i := range unlocks[result.position] {
+			waitingFor[i]--
+			if waitingFor[i] == 0 && skipReasons[i] == "" {
+				// keep ready bricks sorted to start them in the `bricks` order
+				j := sort.SearchInts(ready, i)
+				ready = append(ready, 0)
+				copy(ready[j+1:], ready[j:])
+				ready[j] = i
+			}
+		}
+	}
+
+	// report bricks that haven't been started
+	for i, b := range bricks {
+		if execSummary[i].Brick != nil {
+			continue
+		}
+
+		extools.DisplaySeparatorTo(display, b.Name)
+		if exinfra.IsInterrupted() {
+			execSummary[i] = ExecReport{
+				Brick:    b,
+				Status:   TAG_SKIP,
+				Reason:   REASON_INTERRUPTED,
+				Action:   action,
+				ExitCode: -1,
+			}
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
+			fmt.Fprintf(display, "%s skipped: %s\n\n", action, REASON_INTERRUPTED)
+		} else if skipReasons[i] != "" {
+			execSummary[i] = ExecReport{
+				Brick:    b,
+				Status:   TAG_SKIP,
+				Reason:   skipReasons[i],
+				Action:   action,
+				ExitCode: -1,
+			}
+			fmt.Fprintf(display, "%s skipped: %s\n\n", action, skipReasons[i])
+		} else if skipFollowing {
+			execSummary[i] = ExecReport{
+				Brick:    b,
+				Status:   TAG_SKIP,
+				Reason:   REASON_PREVIOUS_FAILED,
+				Action:   action,
+				ExitCode: -1,
+			}
+			fmt.Fprintf(display, "%s skipped\n\n", action)
+		} else {
+			// NOTE(half-shell): it only happens if some bricks depends on each other
+			execSummary[i] = ExecReport{
+				Brick:    b,
+				Status:   TAG_ERROR,
+				Error:    fmt.Errorf("%s not started: circular dependency between bricks", action),
+				Action:   action,
+				ExitCode: -1,
+			}
+			statusCode = exstatuscode.Update(statusCode, exstatuscode.RUN_ERROR)
+			fmt.Fprintf(display, "%s not started\n\n", action)
+		}
+	}
+
+	return
+}
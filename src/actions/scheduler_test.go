@@ -0,0 +1,205 @@
+package actions
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	exargs "src/exeiac/arguments"
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+)
+
+// Returns an infra of elementary bricks declared as "name:previous1,previous2", and its
+// bricks sorted by index.
+func newSchedulerInfra(t *testing.T, declarations ...string) (*exinfra.Infra, exinfra.Bricks) {
+	t.Helper()
+
+	infra := &exinfra.Infra{Bricks: make(exinfra.BricksMap)}
+	var bricks exinfra.Bricks
+	previousNames := make(map[*exinfra.Brick]string)
+	for index, declaration := range declarations {
+		name, previous, _ := strings.Cut(declaration, ":")
+		b := &exinfra.Brick{Index: index, Name: name, Path: "/infra/" + name + "/", IsElementary: true}
+		infra.Bricks[name] = b
+		bricks = append(bricks, b)
+		previousNames[b] = previous
+	}
+
+	for _, b := range bricks {
+		if previousNames[b] == "" {
+			continue
+		}
+		for _, name := range strings.Split(previousNames[b], ",") {
+			previous, exist := infra.Bricks[name]
+			if !exist {
+				t.Fatalf("brick %s depends on %s that isn't declared", b.Name, name)
+			}
+			b.Inputs = append(b.Inputs, exinfra.Input{VarName: name, Brick: previous, JsonPath: "$"})
+		}
+	}
+
+	return infra, bricks
+}
+
+// Records when each brick's task starts and ends, and how many tasks run at the same time
+type taskRecorder struct {
+	mutex      sync.Mutex
+	events     []string
+	running    int
+	maxRunning int
+}
+
+// Returns a task recording its execution, lasting `duration` and failing for the bricks
+// of `failing`.
+func (r *taskRecorder) task(duration time.Duration, failing ...string) brickTask {
+	return func(b *exinfra.Brick, stdout io.Writer, stderr io.Writer) (
+		report ExecReport, statusCode int, skipFollowing bool,
+	) {
+		r.mutex.Lock()
+		r.events = append(r.events, "start "+b.Name)
+		r.running++
+		if r.running > r.maxRunning {
+			r.maxRunning = r.running
+		}
+		r.mutex.Unlock()
+
+		time.Sleep(duration)
+
+		r.mutex.Lock()
+		r.events = append(r.events, "end "+b.Name)
+		r.running--
+		r.mutex.Unlock()
+
+		for _, f := range failing {
+			if f == b.Name {
+				return ExecReport{Status: TAG_ERROR, ExitCode: 1}, exstatuscode.MODULE_ERROR, true
+			}
+		}
+
+		return ExecReport{Status: TAG_DONE}, 0, false
+	}
+}
+
+// Returns the position of an event, or -1 if it hasn't been recorded
+func (r *taskRecorder) position(event string) int {
+	for i, e := range r.events {
+		if e == event {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestExecuteBricksOrder(t *testing.T) {
+	// a diamond: b and c depend on a, d depends on b and c, e is independent
+	declarations := []string{"a", "b:a", "c:a", "d:b,c", "e"}
+	edges := [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}}
+
+	for _, reverse := range []bool{false, true} {
+		for _, parallelism := range []int{1, 3} {
+			infra, bricks := newSchedulerInfra(t, declarations...)
+			recorder := &taskRecorder{}
+
+			summary, statusCode := executeBricks(infra, bricks, "lay", parallelism, reverse, false, true,
+				recorder.task(5*time.Millisecond))
+			if statusCode != 0 {
+				t.Errorf("reverse=%v, parallelism=%d: status code = %d, expected 0",
+					reverse, parallelism, statusCode)
+			}
+
+			for i, report := range summary {
+				if report.Brick != bricks[i] || report.Status != TAG_DONE {
+					t.Errorf("reverse=%v, parallelism=%d: summary[%d] = %s %v, expected %s DONE",
+						reverse, parallelism, i, report.Status, report.Brick, bricks[i].Name)
+				}
+			}
+
+			for _, edge := range edges {
+				first, then := edge[0], edge[1]
+				if reverse {
+					first, then = then, first
+				}
+				if recorder.position("end "+first) > recorder.position("start "+then) {
+					t.Errorf("reverse=%v, parallelism=%d: %s started before %s ended: %v",
+						reverse, parallelism, then, first, recorder.events)
+				}
+			}
+		}
+	}
+}
+
+func TestExecuteBricksSkip(t *testing.T) {
+	tests := []struct {
+		name        string
+		keepGoing   bool
+		parallelism int
+		// the status of each brick of a -> b -> c, d, with a failing
+		expected []string
+	}{
+		{name: "stop", keepGoing: false, parallelism: 1, expected: []string{TAG_ERROR, TAG_SKIP, TAG_SKIP, TAG_SKIP}},
+		{name: "keep going", keepGoing: true, parallelism: 1, expected: []string{TAG_ERROR, TAG_SKIP, TAG_SKIP, TAG_DONE}},
+		{name: "keep going in parallel", keepGoing: true, parallelism: 2, expected: []string{TAG_ERROR, TAG_SKIP, TAG_SKIP, TAG_DONE}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra, bricks := newSchedulerInfra(t, "a", "b:a", "c:b", "d")
+			recorder := &taskRecorder{}
+
+			summary, statusCode := executeBricks(infra, bricks, "lay", test.parallelism, false, test.keepGoing,
+				true, recorder.task(0, "a"))
+			if statusCode != exstatuscode.MODULE_ERROR {
+				t.Errorf("status code = %d, expected %d", statusCode, exstatuscode.MODULE_ERROR)
+			}
+
+			for i, report := range summary {
+				if report.Status != test.expected[i] {
+					t.Errorf("%s status = %s, expected %s", bricks[i].Name, report.Status, test.expected[i])
+				}
+			}
+			for _, skipped := range []string{"b", "c"} {
+				if recorder.position("start "+skipped) >= 0 {
+					t.Errorf("%s has been executed after a has failed", skipped)
+				}
+			}
+			if test.keepGoing && !strings.Contains(summary[2].Reason, "a") {
+				t.Errorf("c skip reason = %q, expected it to name a", summary[2].Reason)
+			}
+		})
+	}
+}
+
+func TestExecuteBricksParallelismLimit(t *testing.T) {
+	infra, bricks := newSchedulerInfra(t, "a", "b", "c", "d", "e", "f")
+	recorder := &taskRecorder{}
+
+	executeBricks(infra, bricks, "plan", 2, false, false, true, recorder.task(20*time.Millisecond))
+
+	if recorder.maxRunning != 2 {
+		t.Errorf("at most %d bricks have been executed at the same time, expected 2", recorder.maxRunning)
+	}
+}
+
+func TestCheckParallelism(t *testing.T) {
+	tests := []struct {
+		parallelism  int
+		otherOptions []string
+		valid        bool
+	}{
+		{parallelism: 1, otherOptions: nil, valid: true},
+		{parallelism: 4, otherOptions: []string{"--non-interactive"}, valid: true},
+		{parallelism: 4, otherOptions: []string{"--other"}, valid: false},
+	}
+
+	for _, test := range tests {
+		conf := &exargs.Configuration{Parallelism: test.parallelism, OtherOptions: test.otherOptions}
+		if err := checkParallelism(conf); (err == nil) != test.valid {
+			t.Errorf("checkParallelism(-j %d, %v) = %v, expected valid: %v",
+				test.parallelism, test.otherOptions, err, test.valid)
+		}
+	}
+}
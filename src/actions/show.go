@@ -36,7 +36,7 @@ func Show(
 			fmt.Println(brick)
 		}
 	case "output", "outputs", "o":
-		err = enrichDatas(bricksToExecute, infra)
+		err = enrichDatas(bricksToExecute, infra, conf)
 		if err != nil {
 			return exstatuscode.ENRICH_ERROR, err
 		}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 )
 
 // A struct matching the arguments available to be provided through the command line.
@@ -22,6 +23,16 @@ type Arguments struct {
 	ConfigurationFile string
 	ShowUsage         bool
 	ListBricks        bool
+	Parallelism       int
+	RefreshOutputs    bool
+	Since             string
+	Until             string
+	ReportFormat      string
+	ReportFile        string
+	Resume            bool
+	KeepGoing         bool
+	DryRun            bool
+	Timeout           time.Duration
 }
 
 func (a Arguments) String() string {
@@ -45,7 +56,7 @@ func (a Arguments) String() string {
 var actions_list = [...]string{
 	"plan", "lay", "remove", "output", "init", "validate_code", "help",
 	"show_input", "list_elementary_bricks", "cd",
-	"get_brick_path", "get_brick_name"}
+	"get_brick_path", "get_brick_name", "graph", "history", "drift"}
 
 // An array containing all of the supported brick's specifiers
 var AvailableBricksSpecifiers = [...]string{
@@ -53,9 +64,18 @@ var AvailableBricksSpecifiers = [...]string{
 	"direct_previous", "dp",
 	"selected", "s",
 	"direct_next", "dn",
-	"linked_next", "all_next", "ln", "an"}
+	"linked_next", "all_next", "ln", "an",
+	"needed_next", "needed_dependents", "nn"}
+
+// The specifiers selecting the linked next bricks that `lay` only lays if a value they
+// consume has changed
+var NeededNextSpecifiers = [...]string{"needed_next", "needed_dependents", "nn"}
+
+var AvailableReportFormats = [...]string{"text", "json", "junit"}
 
 var AvailableBricksFormat = [...]string{
 	"name", "n",
 	"path", "p",
-	"all", "a"}
+	"all", "a",
+	"dot", "mermaid", "json",
+	"markdown", "md"}
@@ -3,8 +3,10 @@ package arguments
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	extools "src/exeiac/tools"
 
@@ -25,6 +27,9 @@ type ConfigurationFile struct {
 		Name string `yaml:"name"`
 		Path string `yaml:"path"`
 	} `yaml:"modules,flow"`
+	// The timeouts of the modules actions, by action name (e.g. lay: 1h30m).
+	// The "default" one applies to the actions that don't have their own.
+	Timeouts    map[string]string `yaml:"timeouts"`
 	DefaultArgs struct {
 		NonInteractive   bool     `yaml:"non_interactive"`
 		BricksSpecifiers []string `yaml:"bricks_specifiers"`
@@ -47,6 +52,17 @@ type Configuration struct {
 	OtherOptions      []string
 	Rooms             map[string]string
 	ConfigurationFile string
+	Parallelism       int
+	RefreshOutputs    bool
+	Since             string
+	Until             string
+	ReportFormat      string
+	ReportFile        string
+	Resume            bool
+	KeepGoing         bool
+	DryRun            bool
+	Timeouts          map[string]time.Duration
+	Timeout           time.Duration
 }
 
 func (a Configuration) String() string {
@@ -89,12 +105,21 @@ func CreateConfiguration(confFilePath string) (configuration Configuration, err
 		modules[module.Name] = module.Path
 	}
 
+	timeouts := make(map[string]time.Duration)
+	for action, timeout := range confFile.Timeouts {
+		timeouts[action], err = time.ParseDuration(timeout)
+		if err != nil {
+			return configuration, fmt.Errorf("timeout of %s is not valid in %s: %v", action, confFilePath, err)
+		}
+	}
+
 	configuration = Configuration{
 		Rooms:            rooms,
 		Modules:          modules,
 		Interactive:      confFile.DefaultArgs.NonInteractive,
 		BricksSpecifiers: confFile.DefaultArgs.BricksSpecifiers,
 		OtherOptions:     confFile.DefaultArgs.OtherOptions,
+		Timeouts:         timeouts,
 	}
 
 	return
@@ -112,15 +137,12 @@ func CreateConfiguration(confFilePath string) (configuration Configuration, err
 func FromArguments(args Arguments) (configuration Configuration, err error) {
 	var conf Configuration
 
-	if args.ConfigurationFile != "" {
-		conf, err = CreateConfiguration(args.ConfigurationFile)
-	} else {
-		var configFilePath string
-
+	configFilePath := args.ConfigurationFile
+	if configFilePath == "" {
 		configFilePath, err = xdg.SearchConfigFile(CONFIG_FILE)
-		if err == nil {
-			conf, err = CreateConfiguration(configFilePath)
-		}
+	}
+	if err == nil {
+		conf, err = CreateConfiguration(configFilePath)
 	}
 
 	if err != nil {
@@ -137,7 +159,12 @@ func FromArguments(args Arguments) (configuration Configuration, err error) {
 	if err == nil {
 		modules = conf.Modules
 		rooms = conf.Rooms
+		// NOTE(half-shell): the configuration file identifies the infra, e.g. in caches
+		if absolutePath, absErr := filepath.Abs(configFilePath); absErr == nil {
+			configFilePath = absolutePath
+		}
 	} else {
+		configFilePath = ""
 		// NOTE(half-shell): We avoid propagating the error up the call stack
 		// since we're handling it.
 		err = nil
@@ -162,12 +189,23 @@ func FromArguments(args Arguments) (configuration Configuration, err error) {
 		Action:            args.Action,
 		BricksNames:       args.BricksNames,
 		BricksSpecifiers:  extools.Deduplicate(append(conf.BricksSpecifiers, args.BricksSpecifiers...)),
-		ConfigurationFile: args.ConfigurationFile,
+		ConfigurationFile: configFilePath,
 		Format:            args.Format,
 		Interactive:       (conf.Interactive && !args.NonInteractive) || args.Interactive,
 		Modules:           modules,
 		Rooms:             rooms,
 		OtherOptions:      other_options,
+		Parallelism:       args.Parallelism,
+		RefreshOutputs:    args.RefreshOutputs,
+		Since:             args.Since,
+		Until:             args.Until,
+		ReportFormat:      args.ReportFormat,
+		ReportFile:        args.ReportFile,
+		Resume:            args.Resume,
+		KeepGoing:         args.KeepGoing,
+		DryRun:            args.DryRun,
+		Timeouts:          conf.Timeouts,
+		Timeout:           args.Timeout,
 	}
 
 	return
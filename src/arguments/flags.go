@@ -46,6 +46,51 @@ module with an action not handled by exeiac, or to provide extra-options
 to it. Flag with arguments need to be enclosed in double quotes
 (e.g. -o "--myflag myargument",-b)`)
 
+	flag.IntVarP(&Args.Parallelism, "parallelism", "j", 1,
+		`The maximum number of bricks executed at the same time by lay, plan and remove.
+A brick is executed as soon as all the bricks it depends on are done.
+When greater than 1, modules outputs are displayed once their brick is done, and
+--non-interactive is required since modules can't ask for user input.`)
+
+	flag.BoolVar(&Args.RefreshOutputs, "refresh-outputs", false,
+		`Fetch the outputs of the bricks from their modules instead of using the ones
+cached since the brick's directory last changed.`)
+
+	flag.BoolVar(&Args.DryRun, "dry-run", false,
+		`Display what lay, remove, clean, plan and modules actions would do, without executing
+any module: the bricks in their execution order, the inputs written and the outputs needed.
+The plan is displayed as JSON with --report-format=json.`)
+
+	flag.BoolVarP(&Args.KeepGoing, "keep-going", "k", false,
+		`Keep laying (or removing) the bricks that don't depend on a brick that has failed.
+By default, no brick is started anymore once a brick has failed.`)
+
+	flag.DurationVar(&Args.Timeout, "timeout", 0,
+		`The maximum duration of every module execution (e.g. 30m, 1h30m), overriding the
+timeouts of exeiac's configuration and of the bricks. A module exceeding it is stopped
+and its brick fails.`)
+
+	flag.BoolVar(&Args.Resume, "resume", false,
+		`Lay again the bricks of the last lay, of the same configuration file and rooms, that have
+failed, or that have been skipped because of a failure. Outputs are fetched again, unless
+they are cached (see --refresh-outputs). No brick should be specified.`)
+
+	flag.StringVar(&Args.ReportFormat, "report-format", "text",
+		fmt.Sprintf(`The format of the execution report of lay, plan, remove, clean and modules actions.
+A json or junit report is displayed instead of the summary unless --report-file is given,
+the modules outputs being displayed on the standard error.
+Includes: %v`, AvailableReportFormats))
+
+	flag.StringVar(&Args.ReportFile, "report-file", "",
+		"A path to write the execution report to, in the format given by --report-format")
+
+	flag.StringVar(&Args.Since, "since", "",
+		`Only display the history recorded after this date. It can be a date (2006-01-02),
+a date and time (RFC3339 or "2006-01-02 15:04") or a duration (36h, 7d).`)
+
+	flag.StringVar(&Args.Until, "until", "",
+		`Only display the history recorded before this date (same formats as --since).`)
+
 	flag.BoolVarP(&Args.ShowUsage, "help", "h", false, "Show exeiac's help")
 
 	flag.BoolVarP(&Args.ListBricks, "list-bricks", "l", false, "List all the bricks from all rooms")
@@ -62,6 +107,9 @@ to it. Flag with arguments need to be enclosed in double quotes
 		fmt.Println("  help: display this help or the specified help for the brick")
 		fmt.Println("  show: display brick attributes (depends of the format option choosen)")
 		fmt.Println("  clean: remove all files created by exeiac")
+		fmt.Println("  graph: display the bricks dependency graph (dot, mermaid or json format)")
+		fmt.Println("  drift: plan every brick of rooms (all rooms by default) and display a drift report")
+		fmt.Println("  history: display who laid or removed bricks, when and with which result")
 		fmt.Println()
 		fmt.Println("OPTION:")
 		flag.PrintDefaults()
@@ -0,0 +1,178 @@
+package drivers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	exinfra "src/exeiac/infra"
+	exstatuscode "src/exeiac/statuscode"
+	extools "src/exeiac/tools"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The file of the brick's directory explaining how to lay or remove the brick by hand
+const MANUAL_INSTRUCTIONS_FILE = "instructions.md"
+
+// The environment variable listing the files, relative to the brick's directory, whose
+// content is the output of the brick. It is usually set as an input of the brick.
+const MANUAL_FILES_LIST_VAR = "EXEIAC_files_list"
+
+// A module for bricks laid by a human: lay and remove display the brick's instructions and
+// ask for a confirmation once done. The output is built from the json and yaml files listed
+// in MANUAL_FILES_LIST_VAR, each one in a field named after the file without its extension.
+// Since nothing can be checked, plan always reports that the brick may have drifted.
+type Manual struct{}
+
+func init() {
+	exinfra.RegisterDriver(Manual{})
+}
+
+func (Manual) Name() string {
+	return "manual"
+}
+
+func (Manual) Plan(ctx exinfra.DriverContext) (int, error) {
+	fmt.Fprintf(ctx.Stdout, "manual: %s is laid by hand, exeiac can't know if it has drifted\n",
+		ctx.Brick.Name)
+
+	return exstatuscode.MODULE_DRIFT_OR_NOT, nil
+}
+
+func (m Manual) Lay(ctx exinfra.DriverContext) (int, error) {
+	return m.askHuman(ctx, "lay")
+}
+
+func (m Manual) Remove(ctx exinfra.DriverContext) (int, error) {
+	return m.askHuman(ctx, "remove")
+}
+
+func (Manual) Output(ctx exinfra.DriverContext) (int, error) {
+	files, err := filesList(ctx)
+	if err != nil {
+		return exstatuscode.MODULE_ERROR, err
+	}
+
+	output := make(map[string]interface{})
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(ctx.Brick.Path, file))
+		if err != nil {
+			return exstatuscode.MODULE_ERROR, err
+		}
+
+		var value interface{}
+		switch filepath.Ext(file) {
+		case ".json":
+			err = json.Unmarshal(content, &value)
+		case ".yml", ".yaml":
+			err = yaml.Unmarshal(content, &value)
+			value = exinfra.NormalizeYamlValue(value)
+		}
+		if err != nil {
+			return exstatuscode.MODULE_ERROR, fmt.Errorf("unable to read %s: %v", file, err)
+		}
+		output[fileKey(file)] = value
+	}
+
+	content, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return exstatuscode.MODULE_ERROR, err
+	}
+	fmt.Fprintln(ctx.Stdout, string(content))
+
+	return 0, nil
+}
+
+func (Manual) ValidateCode(ctx exinfra.DriverContext) (int, error) {
+	_, err := filesList(ctx)
+	if err != nil {
+		fmt.Fprintf(ctx.Stderr, "manual: %v\n", err)
+
+		return exstatuscode.MODULE_ERROR, nil
+	}
+
+	return 0, nil
+}
+
+// Displays the brick's instructions to lay or remove it, then asks the human to confirm
+// it has been done. Fails in non-interactive mode.
+func (Manual) askHuman(ctx exinfra.DriverContext, action string) (int, error) {
+	if extools.ContainsString(ctx.Args, "--non-interactive") {
+		fmt.Fprintf(ctx.Stderr, "manual: %s has to be %s by hand, it can't be done in non-interactive mode\n",
+			ctx.Brick.Name, pastParticiple(action))
+
+		return exstatuscode.MODULE_ERROR, nil
+	}
+
+	instructions, err := os.ReadFile(filepath.Join(ctx.Brick.Path, MANUAL_INSTRUCTIONS_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(ctx.Stdout, "manual: no %s in %s, %s the brick by hand\n",
+			MANUAL_INSTRUCTIONS_FILE, ctx.Brick.Path, action)
+	} else if err != nil {
+		return exstatuscode.MODULE_ERROR, err
+	} else {
+		fmt.Fprintln(ctx.Stdout, strings.TrimRight(string(instructions), "\n"))
+	}
+
+	fmt.Fprintf(ctx.Stdout, "\033[1mHas %s been %s ? \033[0m\033[3m(only yes accepted): \033[0m",
+		ctx.Brick.Name, pastParticiple(action))
+
+	answer, err := ctx.ReadLine()
+	if ctx.Context.Err() != nil {
+		fmt.Fprintln(ctx.Stdout, "")
+
+		return exstatuscode.MODULE_ERROR, ctx.Context.Err()
+	} else if err != nil {
+		return exstatuscode.MODULE_ERROR, fmt.Errorf("unable to read the confirmation: %v", err)
+	}
+
+	switch strings.TrimSpace(answer) {
+	case "yes", "YES", "Yes":
+		return 0, nil
+	default:
+		fmt.Fprintf(ctx.Stderr, "manual: %s hasn't been %s\n", ctx.Brick.Name, pastParticiple(action))
+
+		return exstatuscode.MODULE_ERROR, nil
+	}
+}
+
+func pastParticiple(action string) string {
+	if action == "lay" {
+		return "laid"
+	}
+
+	return action + "d"
+}
+
+// Returns the field of the output containing a file's content: its name without extension
+func fileKey(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+// Returns the files listed in MANUAL_FILES_LIST_VAR. Returns an error if a file isn't
+// a json or yaml one, or if two files would be in the same output field.
+func filesList(ctx exinfra.DriverContext) (files []string, err error) {
+	list, _ := ctx.LookupEnv(MANUAL_FILES_LIST_VAR)
+	files = strings.Fields(list)
+
+	keys := make(map[string]string)
+	for _, file := range files {
+		switch filepath.Ext(file) {
+		case ".json", ".yml", ".yaml":
+		default:
+			return nil, fmt.Errorf("format of %s not supported: only json and yaml files are", file)
+		}
+
+		key := fileKey(file)
+		if other, exist := keys[key]; exist {
+			return nil, fmt.Errorf("%s and %s would both be the output field %s", other, file, key)
+		}
+		keys[key] = file
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+package drivers
+
+import (
+	"fmt"
+
+	exinfra "src/exeiac/infra"
+)
+
+// A module doing nothing, e.g. for bricks only grouping values consumed by other bricks,
+// or to try exeiac. Its plan never detects a drift and its output is an empty object.
+type Noop struct{}
+
+func init() {
+	exinfra.RegisterDriver(Noop{})
+}
+
+func (Noop) Name() string {
+	return "noop"
+}
+
+func (Noop) Plan(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
+
+func (Noop) Lay(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
+
+func (Noop) Remove(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
+
+func (Noop) Output(ctx exinfra.DriverContext) (int, error) {
+	_, err := fmt.Fprintln(ctx.Stdout, "{}")
+
+	return 0, err
+}
+
+func (Noop) Init(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
+
+func (Noop) Clean(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
+
+func (Noop) ValidateCode(ctx exinfra.DriverContext) (int, error) {
+	return 0, nil
+}
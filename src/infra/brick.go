@@ -1,13 +1,17 @@
 package infra
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/PaesslerAG/jsonpath"
 	"gopkg.in/yaml.v2"
@@ -18,21 +22,131 @@ type Input struct {
 	VarName string
 	// The JSON path to access the variable
 	JsonPath string
-	// A reference to the related brick
+	// A reference to the related brick. Is nil if the data doesn't come from a brick
 	Brick *Brick
-	// The Format can be env, json, yaml, hashicorp
+	// The elementary bricks of the related brick if it is a super-brick, sorted by index
+	SubBricks Bricks
+	// The value of data that doesn't come from a brick
+	Value interface{}
+	// Where data that doesn't come from a brick comes from (value, env:<name> or file:<path>)
+	Source string
+	// The Format can be env, json, yaml, hcl, tfvars.json
 	Format InputFormat
 	// The type can be env, file
 	Type string
 	// The relative path from the brickPath of the file where the input will be written
 	Path string // (obviously it is "" for env_var type)
+	// Wheither the input is merged into an existing file or replaces it (yaml format only)
+	Merge bool
 }
 
 func (i Input) String() string {
+	if i.Brick == nil {
+		return fmt.Sprintf("%s(%s):%s -> %s", i.Path, i.Type, i.VarName, i.Source)
+	}
+
 	return fmt.Sprintf("%s(%s):%s -> %s:%v",
 		i.Path, i.Type, i.VarName, i.Brick.Name, i.JsonPath)
 }
 
+// Describes where the input comes from in the `brick.yml` file
+func (i Input) Origin() string {
+	if i.Brick == nil {
+		return fmt.Sprintf("input.data \"%s\" from %s", i.VarName, i.Source)
+	}
+
+	return fmt.Sprintf("input.data \"%s\" from \"%s:%s\"", i.VarName, i.Brick.Name, i.JsonPath)
+}
+
+// Returns the current output of a brick, to be given to the functions computing an input's
+// value from the outputs of the bricks.
+func CurrentOutput(b *Brick) []byte {
+	return b.Output
+}
+
+// Returns the output the input reads its value from, given the outputs of the elementary
+// bricks by `outputOf`.
+// The output of a super-brick is a JSON object with a field per elementary brick it contains,
+// named after the brick's name relative to the super-brick (e.g. "staging/bastion"), whose
+// value is the brick's output (null if it is unknown).
+func (i Input) SourceOutput(outputOf func(*Brick) []byte) ([]byte, error) {
+	if i.Brick.IsElementary {
+		return outputOf(i.Brick), nil
+	}
+
+	outputs := make(map[string]json.RawMessage)
+	for _, b := range i.SubBricks {
+		output := outputOf(b)
+		if len(bytes.TrimSpace(output)) == 0 {
+			output = []byte("null")
+		}
+		outputs[strings.TrimPrefix(b.Name, i.Brick.Name+"/")] = output
+	}
+
+	return json.Marshal(outputs)
+}
+
+// Returns the value consumed by an input coming from a brick, given the outputs of the
+// elementary bricks by `outputOf`.
+func (i Input) ConsumedValue(outputOf func(*Brick) []byte) (value interface{}, err error) {
+	output, err := i.SourceOutput(outputOf)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JSON that correspond to %s output: %v", i.Brick.Name, err)
+	}
+
+	var content interface{}
+	err = json.Unmarshal(output, &content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse JSON that correspond to %s output: %v", i.Brick.Name, err)
+	}
+	value, err = jsonpath.Get(i.JsonPath, content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to solve JSON path %s of %s output: %v", i.JsonPath, i.Brick.Name, err)
+	}
+
+	return
+}
+
+// Returns a hash of the value consumed by an input coming from a brick, given the outputs of
+// the elementary bricks by `outputOf`. It allows to know if the value has changed without
+// keeping it, since outputs can contain secrets.
+func (i Input) ConsumedValueHash(outputOf func(*Brick) []byte) (string, error) {
+	value, err := i.ConsumedValue(outputOf)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Returns wheither the value consumed by this input differs between the outputs given by
+// `previousOutputOf` and the current outputs of the bricks.
+// Inputs that don't come from a brick never change.
+func (i Input) HasChanged(previousOutputOf func(*Brick) []byte) (bool, error) {
+	if i.Brick == nil {
+		return false, nil
+	}
+
+	currentValue, err := i.ConsumedValue(CurrentOutput)
+	if err != nil {
+		return true, err
+	}
+
+	// NOTE(half-shell): a previous output that can't be read is seen as a change
+	previousValue, err := i.ConsumedValue(previousOutputOf)
+	if err != nil {
+		return true, nil
+	}
+
+	return !reflect.DeepEqual(previousValue, currentValue), nil
+}
+
 type Brick struct {
 	// The brick's index. It represents the absolute brick ordering
 	Index int
@@ -56,6 +170,9 @@ type Brick struct {
 	Inputs []Input
 
 	Output []byte
+	// The timeouts of the module's actions, by action name. TIMEOUT_DEFAULT applies to the
+	// actions that don't have one. An action without timeout can run forever
+	Timeouts map[string]time.Duration
 	// Error from the last call to `Enrich()`
 	EnrichError error
 }
@@ -65,28 +182,67 @@ type BrickConfYaml struct {
 	Version string `yaml:"version"`
 	// The name or path of the module it uses
 	Module string `yaml:"module"`
+	// The timeouts of the module's actions, by action name (e.g. lay: 1h30m), overriding
+	// the ones of exeiac's configuration
+	Timeouts map[string]string `yaml:"timeouts"`
 	// A slice of different kinds of input needed for this brick
 	// It **usually** matches the plain or processed output of another brick
 	Input []struct {
 		// The type of input this brick is expecting
 		// Can match the strings "file" or "env_vars"
 		Type string `yaml:"type"`
-		// Can be json, yaml, env
+		// Can be json, yaml, env (dotenv file), env_export (shell file to source), hcl (e.g. `.auto.tfvars` file)
+		// or tfvars.json (e.g. `.auto.tfvars.json` file)
 		Format string `yaml:"format"`
 		// If the type is a path, it is the path the dependency output should be saved to
 		Path string `yaml:"path"`
-		Data []struct {
-			// The name the variable is expected to have
-			Name string `yaml:"name"`
-			// The key path the input variable should match
-			// It is of the form "<brick_name>:"<json_path>"
-			// OR "<brick_path>:"<json_path>"
-			// e.g. "super-brick/brick:.object.field
-			From string `yaml:"from"`
-		}
+		// If true, the data are merged at the root of the existing file instead of
+		// replacing it. Only supported by the yaml format
+		Merge bool            `yaml:"merge"`
+		Data  []InputDataYaml `yaml:"data"`
 	} `yaml:"input"`
 }
 
+// A data item of a brick's input, telling where its value comes from
+type InputDataYaml struct {
+	// The name the variable is expected to have
+	Name string `yaml:"name"`
+	// The key path the input variable should match
+	// It is of the form "<brick_name>:"<json_path>"
+	// OR "<brick_path>:"<json_path>"
+	// e.g. "super-brick/brick:.object.field
+	// The output of a super-brick has a field per elementary brick it contains
+	// e.g. "super-brick:.brick.object.field"
+	From string `yaml:"from"`
+	// A literal value, used instead of a brick's output
+	Value interface{} `yaml:"value"`
+	// Whether the field value is set, since a null value is a valid literal value
+	HasValue bool `yaml:"-"`
+	// The name of an environment variable of exeiac, used instead of a brick's output
+	Env string `yaml:"env"`
+	// The path of a file relative to the room, whose content is used instead of
+	// a brick's output
+	File string `yaml:"file"`
+}
+
+func (d *InputDataYaml) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// NOTE(half-shell): a distinct type is needed to not call this method recursively
+	type plainInputDataYaml InputDataYaml
+	err := unmarshal((*plainInputDataYaml)(d))
+	if err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+	err = unmarshal(&fields)
+	if err != nil {
+		return err
+	}
+	_, d.HasValue = fields["value"]
+
+	return nil
+}
+
 // Reads a the yaml configuration file and parses it.
 // Returns the parsed configuration.
 // Returns an error because of reading or parsing the file.
@@ -156,21 +312,59 @@ func (brick *Brick) Enrich(bcy BrickConfYaml, infra *Infra) error {
 	}
 
 	brick.Module = module
-	dependencies, err := bcy.resolveDependencies(infra)
+	brick.Timeouts, err = resolveTimeouts(infra, bcy.Timeouts)
 	if err != nil {
-		log.Printf("An error occured when getting dependencies of brick %s: %v\n", brick.Name, err)
+		return err
+	}
+
+	dependencies, err := bcy.resolveDependencies(infra, brick)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the inputs: %v", err)
 	}
 
 	brick.Inputs = dependencies
 
 	for _, i := range brick.Inputs {
-		brick.DirectPrevious = append(brick.DirectPrevious, i.Brick)
+		if i.Brick != nil {
+			brick.DirectPrevious = append(brick.DirectPrevious, i.Brick)
+		}
 	}
 	brick.DirectPrevious = RemoveDuplicates(brick.DirectPrevious)
 
 	return nil
 }
 
+// Returns the timeouts of a brick: the ones of exeiac's configuration overridden by the
+// ones of its `brick.yml`, unless a timeout has been given for the whole execution.
+func resolveTimeouts(infra *Infra, brickTimeouts map[string]string) (map[string]time.Duration, error) {
+	if infra.Timeout > 0 {
+		return map[string]time.Duration{TIMEOUT_DEFAULT: infra.Timeout}, nil
+	}
+
+	timeouts := make(map[string]time.Duration)
+	for action, timeout := range infra.Timeouts {
+		timeouts[action] = timeout
+	}
+	for action, timeout := range brickTimeouts {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("timeout of %s is not valid: %v", action, err)
+		}
+		timeouts[action] = duration
+	}
+
+	return timeouts, nil
+}
+
+// Returns the timeout of an action over the brick, or 0 if it has none
+func (b *Brick) Timeout(action string) time.Duration {
+	if timeout, exist := b.Timeouts[action]; exist {
+		return timeout
+	}
+
+	return b.Timeouts[TIMEOUT_DEFAULT]
+}
+
 // Parses this brick's input brick dependencies JSON output, and creates a map of formatters.
 // Returns a map with the intput file path as the key, and the relevant Formatter as the value.
 // The key is `env` if there is no path and the inputs are supposed to be passed around as
@@ -182,17 +376,16 @@ func (b *Brick) CreateFormatters() (fileFormatters map[string]Formatter, env_for
 	// e.g. rawInputs[<data_format>][<file_path>][<variable_name>] => <variable_value>
 	// NOTE: This is a pretty good use case for a tree-like structure!
 	rawInputs := make(map[InputFormat]map[string]map[string]interface{})
+	// The file paths where inputs are merged instead of being replaced
+	mergedPaths := make(map[string]bool)
 
 	for _, i := range b.Inputs {
-		var output interface{}
-		err := json.Unmarshal(i.Brick.Output, &output)
-		if err != nil {
-			log.Fatalf("Could not parse JSON that correspond to %s output: %v", i.Brick.Name, err)
-		}
-
-		varVal, err := jsonpath.Get(i.JsonPath, output)
-		if err != nil {
-			log.Fatalf("Error happened when solving dependency JSON path of brick %v (jsonPath: '%v'): %v", b.Name, i.JsonPath, err)
+		varVal := i.Value
+		if i.Brick != nil {
+			varVal, err = i.ConsumedValue(CurrentOutput)
+			if err != nil {
+				return nil, nil, fmt.Errorf("input %s: %v", i.VarName, err)
+			}
 		}
 
 		path := filepath.Join(b.Path, i.Path)
@@ -203,6 +396,9 @@ func (b *Brick) CreateFormatters() (fileFormatters map[string]Formatter, env_for
 			rawInputs[i.Format][path] = make(map[string]interface{})
 		}
 		rawInputs[i.Format][path][i.VarName] = varVal
+		if i.Merge {
+			mergedPaths[path] = true
+		}
 	}
 
 	for format, paths := range rawInputs {
@@ -210,9 +406,26 @@ func (b *Brick) CreateFormatters() (fileFormatters map[string]Formatter, env_for
 			switch format {
 			case Json:
 				fileFormatters[path] = JsonFormat(vals)
-			case Env:
+			case Hcl:
+				fileFormatters[path] = HclFormat(vals)
+			case TfvarsJson:
+				fileFormatters[path] = TfvarsJsonFormat(vals)
+			case Yaml:
+				if mergedPaths[path] {
+					fileFormatters[path] = YamlMergeFormat{Path: path, Values: vals}
+				} else {
+					fileFormatters[path] = YamlFormat(vals)
+				}
+			case Env, EnvExport:
 				if path == b.Path {
-					env_formatters = EnvFormat(vals)
+					if env_formatters == nil {
+						env_formatters = make(EnvFormat)
+					}
+					for varName, varVal := range vals {
+						env_formatters[varName] = varVal
+					}
+				} else if format == EnvExport {
+					fileFormatters[path] = ExportEnvFormat(vals)
 				} else {
 					fileFormatters[path] = EnvFormat(vals)
 				}
@@ -232,12 +445,13 @@ func (b *Brick) CreateFormatters() (fileFormatters map[string]Formatter, env_for
 // Loops through a `Brick`'s configuration file's `Input` and builds a slice of `Input`s
 // out of it.
 // The `infra` argument is used to resolve a brick's name to a `Brick` reference.
+// Data that doesn't come from another brick (value, env, file) are resolved right away.
 // Returns an error if something wrong happened during `Brick's` name-to-reference resolution,
 // or when checking that the `JsonPath` is a valid JSONPath.
-func (bcy BrickConfYaml) resolveDependencies(infra *Infra) (inputs []Input, err error) {
+func (bcy BrickConfYaml) resolveDependencies(infra *Infra, brick *Brick) (inputs []Input, err error) {
 	parseFromField := func(from string) (brickName string, dataKey string, err error) {
 		if from == "" {
-			return "", "", fmt.Errorf("field from is empty or doesn't exist")
+			return "", "", fmt.Errorf("none of the fields from, value, env or file is set")
 		}
 
 		fields := strings.Split(from, ":")
@@ -253,6 +467,21 @@ func (bcy BrickConfYaml) resolveDependencies(infra *Infra) (inputs []Input, err
 	}
 
 	for _, i := range bcy.Input {
+		inputFormat, isSupported := SupportedFormats[i.Format]
+		if !isSupported {
+			err = errors.New(fmt.Sprintf("Format %s not supported in %s",
+				i.Format,
+				brick.ConfigurationFilePath))
+
+			return
+		}
+
+		if i.Merge && inputFormat != Yaml {
+			err = fmt.Errorf("merge is only supported for yaml format, not %s", i.Format)
+
+			return
+		}
+
 		for _, d := range i.Data {
 			if d.Name == "" {
 				err = fmt.Errorf("data item hasn't any field \"name\"")
@@ -260,44 +489,90 @@ func (bcy BrickConfYaml) resolveDependencies(infra *Infra) (inputs []Input, err
 				return
 			}
 
-			var brickName string
-			var keyPath string
-			brickName, keyPath, err = parseFromField(d.From)
-			if err != nil {
-				err = fmt.Errorf("data %s: %v", d.Name, err)
+			if (inputFormat == Env || inputFormat == EnvExport) && !envVarNameRegexp.MatchString(d.Name) {
+				err = fmt.Errorf("data %s: not a valid environment variable name", d.Name)
 
 				return
 			}
-			// NOTE(half-shell): We sanitize the brick name here in case they turn
-			// out to be brick paths
-			brick, ok := infra.Bricks[SanitizeBrickName(brickName)]
 
-			if !ok {
-				err = fmt.Errorf("No brick names %s", brickName)
+			input := Input{
+				VarName: d.Name,
+				Format:  inputFormat,
+				Type:    i.Type,
+				Path:    i.Path,
+				Merge:   i.Merge,
+			}
 
-				return
+			sourcesCount := 0
+			for _, isSet := range []bool{d.From != "", d.HasValue, d.Env != "", d.File != ""} {
+				if isSet {
+					sourcesCount++
+				}
 			}
+			if sourcesCount > 1 {
+				err = fmt.Errorf("data %s: only one of the fields from, value, env and file is expected", d.Name)
 
-			// NOTE(half-shell): We make sure the jsonPath's form is valid
-			_, err = jsonpath.New(keyPath)
-			if err != nil {
 				return
 			}
 
-			if inputFormat, isSupported := SupportedFormats[i.Format]; isSupported {
-				inputs = append(inputs, Input{
-					VarName:  d.Name,
-					JsonPath: keyPath,
-					Brick:    brick,
-					Format:   inputFormat,
-					Type:     i.Type,
-					Path:     i.Path,
-				})
-			} else {
-				err = errors.New(fmt.Sprintf("Format %s not supported in %s",
-					i.Type,
-					brick.ConfigurationFilePath))
+			switch {
+			case d.HasValue:
+				input.Value = NormalizeYamlValue(d.Value)
+				input.Source = "value"
+			case d.Env != "":
+				value, isSet := os.LookupEnv(d.Env)
+				if !isSet {
+					err = fmt.Errorf("data %s: environment variable %s is not set", d.Name, d.Env)
+
+					return
+				}
+				input.Value = value
+				input.Source = "env:" + d.Env
+			case d.File != "":
+				var content []byte
+				content, err = os.ReadFile(filepath.Join(brick.Room.Path, d.File))
+				if err != nil {
+					err = fmt.Errorf("data %s: %v", d.Name, err)
+
+					return
+				}
+				input.Value = string(content)
+				input.Source = "file:" + d.File
+			default:
+				var brickName string
+				var keyPath string
+				brickName, keyPath, err = parseFromField(d.From)
+				if err != nil {
+					err = fmt.Errorf("data %s: %v", d.Name, err)
+
+					return
+				}
+				// NOTE(half-shell): We sanitize the brick name here in case they turn
+				// out to be brick paths
+				b, ok := infra.Bricks[SanitizeBrickName(brickName)]
+
+				if !ok {
+					err = fmt.Errorf("No brick names %s", brickName)
+
+					return
+				}
+
+				// NOTE(half-shell): We make sure the jsonPath's form is valid
+				_, err = jsonpath.New(keyPath)
+				if err != nil {
+					err = fmt.Errorf("data %s: invalid JSON path %s: %v", d.Name, keyPath, err)
+
+					return
+				}
+
+				input.Brick = b
+				input.JsonPath = keyPath
+				if !b.IsElementary {
+					input.SubBricks = infra.subBricks(b)
+				}
 			}
+
+			inputs = append(inputs, input)
 		}
 	}
 
@@ -0,0 +1,174 @@
+package infra
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestInputDataYamlHasValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		hasValue bool
+	}{
+		{name: "value", data: "{name: a, value: 1}", hasValue: true},
+		{name: "null value", data: "{name: a, value: null}", hasValue: true},
+		{name: "empty value", data: "{name: a, value: }", hasValue: true},
+		{name: "no value", data: "{name: a, from: b:.c}", hasValue: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var d InputDataYaml
+			if err := yaml.Unmarshal([]byte(test.data), &d); err != nil {
+				t.Fatalf("Unmarshal(): %v", err)
+			}
+			if d.Name != "a" {
+				t.Errorf("Name = %q, expected \"a\"", d.Name)
+			}
+			if d.HasValue != test.hasValue {
+				t.Errorf("HasValue = %v, expected %v", d.HasValue, test.hasValue)
+			}
+		})
+	}
+}
+
+func TestInputHasChangedFromSuperBrick(t *testing.T) {
+	infra := newTestInfra(t, "room/", "room/net/", "room/net/vpc", "room/app")
+	room := infra.Bricks["room"]
+	vpc := infra.Bricks["room/net/vpc"]
+	app := infra.Bricks["room/app"]
+	input := Input{VarName: "cidr", Brick: room, SubBricks: infra.subBricks(room), JsonPath: `$["net/vpc"].cidr`}
+
+	vpcBefore := []byte(`{"cidr": "10.0.0.0/16", "id": "vpc-1"}`)
+	appBefore := []byte(`{"url": "https://app"}`)
+	previousOutputOf := func(b *Brick) []byte {
+		return map[*Brick][]byte{vpc: vpcBefore, app: appBefore}[b]
+	}
+
+	tests := []struct {
+		name      string
+		vpcOutput string
+		appOutput string
+		expected  bool
+	}{
+		{name: "nothing changed", vpcOutput: string(vpcBefore), appOutput: string(appBefore), expected: false},
+		{name: "another brick changed", vpcOutput: string(vpcBefore), appOutput: `{"url": "https://new"}`, expected: false},
+		{name: "another value changed", vpcOutput: `{"cidr": "10.0.0.0/16", "id": "vpc-2"}`, appOutput: string(appBefore), expected: false},
+		{name: "consumed value changed", vpcOutput: `{"cidr": "10.1.0.0/16", "id": "vpc-1"}`, appOutput: string(appBefore), expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			vpc.Output = []byte(test.vpcOutput)
+			app.Output = []byte(test.appOutput)
+
+			changed, err := input.HasChanged(previousOutputOf)
+			if err != nil {
+				t.Fatalf("HasChanged(): %v", err)
+			}
+			if changed != test.expected {
+				t.Errorf("HasChanged() = %v, expected %v", changed, test.expected)
+			}
+		})
+	}
+
+	value, err := input.ConsumedValue(CurrentOutput)
+	if err != nil || value != "10.1.0.0/16" {
+		t.Errorf("ConsumedValue() = %v, %v, expected the cidr of room/net/vpc", value, err)
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	infra := newTestInfra(t, "room/", "room/vpc")
+	room := infra.Bricks["room"]
+	room.Path = t.TempDir()
+	if err := os.WriteFile(filepath.Join(room.Path, "key.pub"), []byte("ssh-ed25519 AAAA"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	brick := &Brick{Name: "room/app", Path: filepath.Join(room.Path, "app"), IsElementary: true, Room: room}
+	t.Setenv("EXEIAC_TEST_REGION", "eu-west-1")
+
+	tests := []struct {
+		name string
+		data string
+		// The expected input, ignored if an error is expected
+		expected Input
+		err      bool
+	}{
+		{
+			name:     "value",
+			data:     "{name: port, value: 8080}",
+			expected: Input{VarName: "port", Value: 8080, Source: "value"},
+		},
+		{
+			name:     "null value",
+			data:     "{name: port, value: null}",
+			expected: Input{VarName: "port", Value: nil, Source: "value"},
+		},
+		{
+			name:     "env",
+			data:     "{name: region, env: EXEIAC_TEST_REGION}",
+			expected: Input{VarName: "region", Value: "eu-west-1", Source: "env:EXEIAC_TEST_REGION"},
+		},
+		{
+			name:     "file relative to the room",
+			data:     "{name: key, file: key.pub}",
+			expected: Input{VarName: "key", Value: "ssh-ed25519 AAAA", Source: "file:key.pub"},
+		},
+		{
+			name:     "brick",
+			data:     "{name: cidr, from: room/vpc:$.cidr}",
+			expected: Input{VarName: "cidr", Brick: infra.Bricks["room/vpc"], JsonPath: "$.cidr"},
+		},
+		{name: "several sources", data: "{name: port, value: 8080, env: EXEIAC_TEST_REGION}", err: true},
+		{name: "no source", data: "{name: port}", err: true},
+		{name: "missing env variable", data: "{name: region, env: EXEIAC_TEST_UNSET}", err: true},
+		{name: "missing file", data: "{name: key, file: missing.pub}", err: true},
+		{name: "unknown brick", data: "{name: cidr, from: room/unknown:$.cidr}", err: true},
+		{name: "invalid JSON path", data: `{name: cidr, from: "room/vpc:$.["}`, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var bcy BrickConfYaml
+			conf := "input:\n- type: env_vars\n  format: env\n  data:\n  - " + test.data + "\n"
+			if err := yaml.Unmarshal([]byte(conf), &bcy); err != nil {
+				t.Fatalf("Unmarshal(): %v", err)
+			}
+
+			inputs, err := bcy.resolveDependencies(infra, brick)
+			if test.err {
+				if err == nil {
+					t.Errorf("resolveDependencies() = %v, expected an error", inputs)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveDependencies(): %v", err)
+			}
+
+			test.expected.Format = Env
+			test.expected.Type = "env_vars"
+			if len(inputs) != 1 || !reflect.DeepEqual(inputs[0], test.expected) {
+				t.Errorf("resolveDependencies() = %#v, expected [%#v]", inputs, test.expected)
+			}
+		})
+	}
+}
+
+func TestInputConsumedValueUnresolved(t *testing.T) {
+	infra := newTestInfra(t, "room/vpc")
+	vpc := infra.Bricks["room/vpc"]
+	vpc.Output = []byte(`{"cidr": "10.0.0.0/16"}`)
+	input := Input{VarName: "id", Brick: vpc, JsonPath: "$.id"}
+
+	if value, err := input.ConsumedValue(CurrentOutput); err == nil {
+		t.Errorf("ConsumedValue() = %v, expected an error since room/vpc has no id", value)
+	}
+}
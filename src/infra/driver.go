@@ -0,0 +1,286 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	exstatuscode "src/exeiac/statuscode"
+)
+
+const ACTION_CLEAN = "clean"
+const ACTION_VALIDATE_CODE = "validate_code"
+
+// The prefix of the path of modules implemented by a driver, e.g. builtin:noop
+const BUILTIN_MODULE_PREFIX = "builtin:"
+
+// What a driver gets to execute an action over a brick
+type DriverContext struct {
+	// Cancelled if the action times out or if exeiac is interrupted
+	Context context.Context
+	Brick   *Brick
+	// The extra options of the action (e.g. --non-interactive)
+	Args []string
+	// The environment variables an executable module would get: exeiac's ones, the EXEIAC_*
+	// ones describing the brick and the brick's inputs
+	Env    []string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Returns the value of an environment variable of the context, and wheither it exists
+func (ctx DriverContext) LookupEnv(name string) (string, bool) {
+	// NOTE(half-shell): the last definition wins, as with executable modules
+	for i := len(ctx.Env) - 1; i >= 0; i-- {
+		if key, value, found := strings.Cut(ctx.Env[i], "="); found && key == name {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// Reads a line of the context's Stdin, without its end of line. Stops waiting for it as soon
+// as the context is done, returning the context's error.
+// NOTE(half-shell): Stdin is read byte by byte and nothing is left reading it once this
+// returns, so that the next lines stay available to the next driver or module reading it.
+func (ctx DriverContext) ReadLine() (string, error) {
+	file, isFile := ctx.Stdin.(*os.File)
+
+	var line []byte
+	buffer := make([]byte, 1)
+	for {
+		var err error
+		if isFile {
+			err = waitForInput(ctx.Context, file)
+		} else {
+			err = ctx.Context.Err()
+		}
+		if err != nil {
+			return "", err
+		}
+
+		n, err := ctx.Stdin.Read(buffer)
+		if n > 0 {
+			if buffer[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buffer[0])
+		}
+		if errors.Is(err, io.EOF) && len(line) > 0 {
+			return string(line), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// A module implemented in Go and executed in exeiac's process instead of an executable.
+// A driver implements the actions it supports among Planner, Layer, Remover, Outputter,
+// Initializer, Cleaner and CodeValidator, with the same status codes as executable modules.
+// Drivers are registered with RegisterDriver and used by modules whose path is
+// BUILTIN_MODULE_PREFIX followed by the driver's name.
+type Driver interface {
+	// The name of the driver, unique among the registered drivers
+	Name() string
+}
+
+type Planner interface {
+	Plan(ctx DriverContext) (statusCode int, err error)
+}
+
+type Layer interface {
+	Lay(ctx DriverContext) (statusCode int, err error)
+}
+
+type Remover interface {
+	Remove(ctx DriverContext) (statusCode int, err error)
+}
+
+// The output has to be written as JSON in the context's Stdout
+type Outputter interface {
+	Output(ctx DriverContext) (statusCode int, err error)
+}
+
+type Initializer interface {
+	Init(ctx DriverContext) (statusCode int, err error)
+}
+
+type Cleaner interface {
+	Clean(ctx DriverContext) (statusCode int, err error)
+}
+
+type CodeValidator interface {
+	ValidateCode(ctx DriverContext) (statusCode int, err error)
+}
+
+var drivers = struct {
+	mutex    sync.Mutex
+	registry map[string]Driver
+}{registry: make(map[string]Driver)}
+
+// Registers a driver, usually from the `init` function of its package.
+// Panics if a driver with the same name is already registered.
+func RegisterDriver(d Driver) {
+	drivers.mutex.Lock()
+	defer drivers.mutex.Unlock()
+
+	if _, exist := drivers.registry[d.Name()]; exist {
+		panic(fmt.Sprintf("driver %s is already registered", d.Name()))
+	}
+	drivers.registry[d.Name()] = d
+}
+
+// Returns the driver of a module path of the form builtin:<name>, or nil if the path isn't
+// the one of a built-in module. Returns an error if no driver has this name.
+func getDriver(path string) (Driver, error) {
+	if !strings.HasPrefix(path, BUILTIN_MODULE_PREFIX) {
+		return nil, nil
+	}
+
+	drivers.mutex.Lock()
+	defer drivers.mutex.Unlock()
+
+	name := strings.TrimPrefix(path, BUILTIN_MODULE_PREFIX)
+	d, exist := drivers.registry[name]
+	if !exist {
+		var names []string
+		for n := range drivers.registry {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("no built-in module named %s (available: %v)", name, names)
+	}
+
+	return d, nil
+}
+
+// Returns the function executing an action of a driver, or nil if it doesn't implement it
+func driverAction(d Driver, action string) func(DriverContext) (int, error) {
+	switch action {
+	case ACTION_PLAN:
+		if p, implemented := d.(Planner); implemented {
+			return p.Plan
+		}
+	case ACTION_LAY:
+		if l, implemented := d.(Layer); implemented {
+			return l.Lay
+		}
+	case ACTION_REMOVE:
+		if r, implemented := d.(Remover); implemented {
+			return r.Remove
+		}
+	case ACTION_OUTPUT:
+		if o, implemented := d.(Outputter); implemented {
+			return o.Output
+		}
+	case ACTION_INIT:
+		if i, implemented := d.(Initializer); implemented {
+			return i.Init
+		}
+	case ACTION_CLEAN:
+		if c, implemented := d.(Cleaner); implemented {
+			return c.Clean
+		}
+	case ACTION_VALIDATE_CODE:
+		if v, implemented := d.(CodeValidator); implemented {
+			return v.ValidateCode
+		}
+	}
+
+	return nil
+}
+
+// Describes a driver as the describe action of an executable module would. Drivers accept
+// --non-interactive for every action, and their plan, output and validate_code actions are
+// seen as read-only.
+func describeDriver(d Driver) (description moduleDescription) {
+	description.ProtocolVersion = MODULE_PROTOCOL_VERSION
+	description.NonInteractiveActions = []string{}
+
+	actions := []string{ACTION_PLAN, ACTION_LAY, ACTION_REMOVE, ACTION_OUTPUT,
+		ACTION_INIT, ACTION_CLEAN, ACTION_VALIDATE_CODE}
+	for _, action := range actions {
+		if driverAction(d, action) == nil {
+			continue
+		}
+
+		description.Actions = append(description.Actions, action)
+		description.NonInteractiveActions = append(description.NonInteractiveActions, action)
+		if action == ACTION_PLAN || action == ACTION_OUTPUT || action == ACTION_VALIDATE_CODE {
+			description.ReadOnlyActions = append(description.ReadOnlyActions, action)
+		}
+	}
+
+	return
+}
+
+// Executes a driver's action, stopping it if it times out or if exeiac is interrupted.
+// Returns an ExecTimeoutError or an ExecInterruptedError if the action has been stopped
+// and hasn't succeeded. A driver panicking fails with MODULE_ERROR, the panic's stack
+// being written in `stderr`.
+// NOTE(half-shell): unlike an executable module, a driver can't be killed: it has to stop
+// by itself once its context is done.
+func (m *Module) execDriver(
+	brick *Brick,
+	action string,
+	args []string,
+	env []string,
+	stdout io.Writer,
+	stderr io.Writer,
+) (
+	statusCode int,
+	err error,
+) {
+	if sig := InterruptionSignal(); sig != nil {
+		return 0, ExecInterruptedError{Action: action, Signal: sig}
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	timeout := brick.Timeout(action)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(interruption.ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(interruption.ctx)
+	}
+	defer cancel()
+
+	// NOTE(half-shell): a driver runs in exeiac's process, its panic shouldn't stop the
+	// other bricks nor prevent exeiac from reporting them
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(stderr, "panic: %v\n\n%s", r, debug.Stack())
+			statusCode = exstatuscode.MODULE_ERROR
+			err = fmt.Errorf("driver %s has panicked during %s: %v", m.Driver.Name(), action, r)
+		}
+	}()
+
+	statusCode, err = driverAction(m.Driver, action)(DriverContext{
+		Context: ctx,
+		Brick:   brick,
+		Args:    args,
+		Env:     env,
+		Stdin:   os.Stdin,
+		Stdout:  stdout,
+		Stderr:  stderr,
+	})
+
+	if err != nil || statusCode != 0 {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 0, ExecTimeoutError{Action: action, Timeout: timeout}
+		} else if sig := InterruptionSignal(); sig != nil {
+			return 0, ExecInterruptedError{Action: action, Signal: sig}
+		}
+	}
+
+	return
+}
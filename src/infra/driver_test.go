@@ -0,0 +1,130 @@
+package infra
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	exstatuscode "src/exeiac/statuscode"
+)
+
+// A driver only implementing lay, whose behaviour is set by each test
+type mockDriver struct {
+	lay func(ctx DriverContext) (int, error)
+}
+
+var mock = &mockDriver{}
+
+func init() {
+	RegisterDriver(mock)
+}
+
+func (*mockDriver) Name() string {
+	return "mock"
+}
+
+func (d *mockDriver) Lay(ctx DriverContext) (int, error) {
+	return d.lay(ctx)
+}
+
+// Returns a brick using the mock driver, and its module loaded as exeiac does
+func newMockBrick(t *testing.T) *Brick {
+	t.Helper()
+
+	room := &Brick{Name: "room", Path: "/infra/room/"}
+	module := &Module{Name: "mock", Path: BUILTIN_MODULE_PREFIX + "mock"}
+	if err := module.LoadDescription(); err != nil {
+		t.Fatalf("LoadDescription(): %v", err)
+	}
+
+	return &Brick{
+		Name:         "room/brick",
+		Path:         "/infra/room/1-brick/",
+		IsElementary: true,
+		Room:         room,
+		Module:       module,
+	}
+}
+
+func TestModuleExecDriver(t *testing.T) {
+	b := newMockBrick(t)
+	var got DriverContext
+	mock.lay = func(ctx DriverContext) (int, error) {
+		got = ctx
+		ctx.Stdout.Write([]byte("laid"))
+
+		return exstatuscode.MODULE_DRIFT, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	statusCode, err := b.Module.Exec(b, "lay", []string{"--non-interactive"}, []string{"NAME=value"},
+		&stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Exec(): %v", err)
+	}
+	if statusCode != exstatuscode.MODULE_DRIFT {
+		t.Errorf("status code = %d, expected %d", statusCode, exstatuscode.MODULE_DRIFT)
+	}
+	if stdout.String() != "laid" {
+		t.Errorf("stdout = %q, expected \"laid\"", stdout.String())
+	}
+
+	if got.Brick != b {
+		t.Errorf("the driver got the brick %v, expected %s", got.Brick, b.Name)
+	}
+	if len(got.Args) != 1 || got.Args[0] != "--non-interactive" {
+		t.Errorf("the driver got the arguments %v, expected [--non-interactive]", got.Args)
+	}
+	for name, expected := range map[string]string{"NAME": "value", "EXEIAC_BRICK_NAME": b.Name} {
+		if value, exist := got.LookupEnv(name); value != expected {
+			t.Errorf("the driver got %s=%q (set: %v), expected %q", name, value, exist, expected)
+		}
+	}
+}
+
+func TestModuleExecDriverNotImplemented(t *testing.T) {
+	b := newMockBrick(t)
+
+	_, err := b.Module.Exec(b, "plan", nil, nil)
+	if !errors.As(err, &ActionNotImplementedError{}) {
+		t.Errorf("Exec() error = %v, expected an ActionNotImplementedError", err)
+	}
+}
+
+func TestModuleExecDriverPanic(t *testing.T) {
+	b := newMockBrick(t)
+	mock.lay = func(ctx DriverContext) (int, error) {
+		var brick *Brick
+
+		return 0, errors.New(brick.Name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	statusCode, err := b.Module.Exec(b, "lay", nil, nil, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("Exec() error = %v, expected the panic to be reported", err)
+	}
+	if statusCode != exstatuscode.MODULE_ERROR {
+		t.Errorf("status code = %d, expected %d", statusCode, exstatuscode.MODULE_ERROR)
+	}
+	if !strings.Contains(stderr.String(), "nil pointer dereference") {
+		t.Errorf("stderr = %q, expected the panic and its stack", stderr.String())
+	}
+}
+
+func TestModuleExecDriverTimeout(t *testing.T) {
+	b := newMockBrick(t)
+	b.Timeouts = map[string]time.Duration{"lay": 10 * time.Millisecond}
+	mock.lay = func(ctx DriverContext) (int, error) {
+		<-ctx.Context.Done()
+
+		return exstatuscode.MODULE_ERROR, ctx.Context.Err()
+	}
+
+	_, err := b.Module.Exec(b, "lay", nil, nil)
+	if !errors.As(err, &ExecTimeoutError{}) {
+		t.Errorf("Exec() error = %v, expected an ExecTimeoutError", err)
+	}
+}
@@ -1,6 +1,11 @@
 package infra
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
 
 type RoomError struct {
 	id     string
@@ -22,6 +27,29 @@ func (e ErrBrickNotFound) Error() string {
 	return fmt.Sprintf("Brick not found: %s", e.brick)
 }
 
+type ErrCircularDependency struct {
+	Cycle []Dependency
+}
+
+func (e ErrCircularDependency) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("! Error: circular dependency between bricks: ")
+	for _, d := range e.Cycle {
+		sb.WriteString(d.Next.Name + " -> ")
+	}
+	sb.WriteString(e.Cycle[0].Next.Name)
+
+	for _, d := range e.Cycle {
+		sb.WriteString(fmt.Sprintf("\n< %v: %s", d, d.Inputs[0].Origin()))
+		for _, i := range d.Inputs[1:] {
+			sb.WriteString(fmt.Sprintf(", %s", i.Origin()))
+		}
+	}
+
+	return sb.String()
+}
+
 type ActionNotImplementedError struct {
 	Action string
 	Module *Module
@@ -43,3 +71,21 @@ func (e ErrBadArg) Error() string {
 		return fmt.Sprintf("! Bad argument: %s: %s", e.Reason, e.Value)
 	}
 }
+
+type ExecTimeoutError struct {
+	Action  string
+	Timeout time.Duration
+}
+
+func (e ExecTimeoutError) Error() string {
+	return fmt.Sprintf("%s has timed out after %v", e.Action, e.Timeout)
+}
+
+type ExecInterruptedError struct {
+	Action string
+	Signal os.Signal
+}
+
+func (e ExecInterruptedError) Error() string {
+	return fmt.Sprintf("%s has been interrupted by %v", e.Action, e.Signal)
+}
@@ -2,31 +2,87 @@ package infra
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	extools "src/exeiac/tools"
+
+	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v2"
 )
 
 type InputFormat string
 
 const (
-	Json = InputFormat("json")
-	Yaml = InputFormat("yaml")
-	Env  = InputFormat("env")
+	Json       = InputFormat("json")
+	Yaml       = InputFormat("yaml")
+	Env        = InputFormat("env")
+	EnvExport  = InputFormat("env_export")
+	Hcl        = InputFormat("hcl")
+	TfvarsJson = InputFormat("tfvars.json")
 )
 
 var SupportedFormats = map[string]InputFormat{
-	"json": Json,
-	"yaml": Yaml,
-	"env":  Env,
+	"json":        Json,
+	"yaml":        Yaml,
+	"env":         Env,
+	"env_export":  EnvExport,
+	"hcl":         Hcl,
+	"tfvars.json": TfvarsJson,
 }
 
+// Matches a valid environment variable name
+var envVarNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Matches a valid HCL identifier (e.g. a terraform variable name)
+var hclIdentifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
 // A formatter is an interface that allows to produce an output in a specific format.
 type Formatter interface {
 	Format() (input []byte, err error)
 }
 
+// A formatter writing its input into an existing file instead of replacing it.
+type MergingFormatter interface {
+	Formatter
+	// Saves what `Format()` is about to replace in the file, so that `Unmerge()` can
+	// restore it. Has to be called before the formatted input is written.
+	SaveMerged() error
+	// Restores the file as it was before the input was merged by `Format()`
+	Unmerge() error
+}
+
 type JsonFormat map[string]interface{}
 type EnvFormat map[string]interface{}
+type ExportEnvFormat map[string]interface{}
+type YamlFormat map[string]interface{}
+
+// Writes variables as HCL attributes, like in terraform's `.auto.tfvars` files.
+type HclFormat map[string]interface{}
+
+// Writes variables as a JSON object, like in terraform's `.auto.tfvars.json` files.
+type TfvarsJsonFormat map[string]interface{}
+
+// The directory, relative to the XDG state directory, where the values replaced by merges
+// in yaml input files are saved until the brick is cleaned
+const MERGE_BACKUP_DIR = "exeiac/merged"
+
+// Merges variables at the root of an existing yaml file, replacing the ones already defined.
+// The other keys of the file, and their order, are kept.
+type YamlMergeFormat struct {
+	// The path of the yaml file to merge variables in
+	Path   string
+	Values map[string]interface{}
+}
 
 func (i JsonFormat) Format() (input []byte, err error) {
 	input, err = json.MarshalIndent(i, "", "\t")
@@ -34,10 +90,433 @@ func (i JsonFormat) Format() (input []byte, err error) {
 	return
 }
 
+func (i YamlFormat) Format() (input []byte, err error) {
+	input, err = yaml.Marshal(map[string]interface{}(i))
+
+	return
+}
+
+// Reads the yaml file to merge variables in. A missing file is considered empty.
+func (i YamlMergeFormat) read() (content yaml.MapSlice, err error) {
+	file, err := os.ReadFile(i.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return yaml.MapSlice{}, nil
+	} else if err != nil {
+		return
+	}
+
+	err = yaml.Unmarshal(file, &content)
+	if err != nil {
+		err = fmt.Errorf("unable to merge input in %s: %v", i.Path, err)
+	}
+
+	return
+}
+
+func (i YamlMergeFormat) Format() (input []byte, err error) {
+	content, err := i.read()
+	if err != nil {
+		return
+	}
+
+	var varNames []string
+	for varName := range i.Values {
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	for _, varName := range varNames {
+		merged := false
+		for index, item := range content {
+			if fmt.Sprintf("%v", item.Key) == varName {
+				content[index].Value = i.Values[varName]
+				merged = true
+			}
+		}
+
+		if !merged {
+			content = append(content, yaml.MapItem{Key: varName, Value: i.Values[varName]})
+		}
+	}
+
+	input, err = yaml.Marshal(content)
+
+	return
+}
+
+// What a merge has replaced in a yaml file, saved to restore the file when its brick is cleaned
+type mergeBackup struct {
+	// Wheither or not the file has been created by the merge
+	Created bool `yaml:"created"`
+	// The values of the file replaced by the merge
+	Replaced yaml.MapSlice `yaml:"replaced"`
+	// The keys added to the file by the merge
+	Added []string `yaml:"added"`
+}
+
+func (backup mergeBackup) records(key string) bool {
+	for _, item := range backup.Replaced {
+		if fmt.Sprintf("%v", item.Key) == key {
+			return true
+		}
+	}
+
+	return extools.ContainsString(backup.Added, key)
+}
+
+func (i YamlMergeFormat) backupFilePath() (string, error) {
+	hash := sha256.Sum256([]byte(i.Path))
+
+	return xdg.StateFile(filepath.Join(MERGE_BACKUP_DIR, hex.EncodeToString(hash[:])+".yml"))
+}
+
+// Reads the backup of the merges in the file. Returns nil if the file has no merge to undo.
+func (i YamlMergeFormat) readBackup() (backup *mergeBackup, backupPath string, err error) {
+	backupPath, err = i.backupFilePath()
+	if err != nil {
+		return
+	}
+
+	content, err := os.ReadFile(backupPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, backupPath, nil
+	} else if err != nil {
+		return
+	}
+
+	backup = &mergeBackup{}
+	err = yaml.Unmarshal(content, backup)
+	if err != nil {
+		err = fmt.Errorf("unable to read what has been merged in %s: %v", i.Path, err)
+	}
+
+	return
+}
+
+// NOTE(half-shell): a file merged again before being cleaned keeps its first backup, so that
+// the values it had before exeiac ever merged in it are the ones restored.
+func (i YamlMergeFormat) SaveMerged() error {
+	backup, backupPath, err := i.readBackup()
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(i.Path)
+	if backup == nil {
+		backup = &mergeBackup{Created: errors.Is(statErr, os.ErrNotExist)}
+	}
+
+	content, err := i.read()
+	if err != nil {
+		return err
+	}
+
+	var varNames []string
+	for varName := range i.Values {
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	for _, varName := range varNames {
+		if backup.records(varName) {
+			continue
+		}
+
+		replaced := false
+		for _, item := range content {
+			if fmt.Sprintf("%v", item.Key) == varName {
+				backup.Replaced = append(backup.Replaced, yaml.MapItem{Key: varName, Value: item.Value})
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			backup.Added = append(backup.Added, varName)
+		}
+	}
+
+	data, err := yaml.Marshal(backup)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(backupPath, data, 0600)
+}
+
+// Puts back the values replaced by the merges and removes the keys they added. A file created
+// by a merge is removed if nothing else has been written in it. Nothing is done if exeiac
+// hasn't merged anything in the file, or if the file doesn't exist anymore.
+func (i YamlMergeFormat) Unmerge() error {
+	backup, backupPath, err := i.readBackup()
+	if err != nil || backup == nil {
+		return err
+	}
+
+	if _, err = os.Stat(i.Path); errors.Is(err, os.ErrNotExist) {
+		return os.Remove(backupPath)
+	}
+
+	content, err := i.read()
+	if err != nil {
+		return err
+	}
+
+	unmerged := yaml.MapSlice{}
+	for _, item := range content {
+		key := fmt.Sprintf("%v", item.Key)
+		if extools.ContainsString(backup.Added, key) {
+			continue
+		}
+		for _, replaced := range backup.Replaced {
+			if fmt.Sprintf("%v", replaced.Key) == key {
+				item.Value = replaced.Value
+			}
+		}
+		unmerged = append(unmerged, item)
+	}
+
+	if backup.Created && len(unmerged) == 0 {
+		err = os.Remove(i.Path)
+	} else {
+		var data []byte
+		data, err = yaml.Marshal(unmerged)
+		if err == nil {
+			err = os.WriteFile(i.Path, data, 0666)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(backupPath)
+}
+
+func (i TfvarsJsonFormat) Format() (input []byte, err error) {
+	for varName := range i {
+		if !hclIdentifierRegexp.MatchString(varName) {
+			return nil, fmt.Errorf("%s is not a valid terraform variable name", varName)
+		}
+	}
+
+	input, err = json.MarshalIndent(i, "", "  ")
+
+	return
+}
+
+func (i HclFormat) Format() (input []byte, err error) {
+	var varNames []string
+	for varName := range i {
+		if !hclIdentifierRegexp.MatchString(varName) {
+			return nil, fmt.Errorf("%s is not a valid HCL identifier", varName)
+		}
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	buf := new(bytes.Buffer)
+	for _, varName := range varNames {
+		var value string
+		value, err = formatHclValue(i[varName], "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to format %s in HCL: %v", varName, err)
+		}
+		buf.WriteString(fmt.Sprintf("%s = %s\n", varName, value))
+	}
+
+	input = buf.Bytes()
+
+	return
+}
+
+// Escapes a string into an HCL quoted template, disabling its interpolation
+// and directive sequences.
+func quoteHclString(s string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\"")
+	for index, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString("\\\\")
+		case '"':
+			sb.WriteString("\\\"")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\t':
+			sb.WriteString("\\t")
+		case '$', '%':
+			sb.WriteRune(r)
+			if strings.HasPrefix(s[index+1:], "{") {
+				sb.WriteRune(r)
+			}
+		default:
+			if r < 0x20 {
+				sb.WriteString(fmt.Sprintf("\\u%04x", r))
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteString("\"")
+
+	return sb.String()
+}
+
+// Converts the maps of a value decoded from yaml into maps with string keys,
+// as the ones decoded from JSON.
+func NormalizeYamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{})
+		for key, item := range v {
+			normalized[fmt.Sprintf("%v", key)] = NormalizeYamlValue(item)
+		}
+
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for index, item := range v {
+			normalized[index] = NormalizeYamlValue(item)
+		}
+
+		return normalized
+	default:
+		return v
+	}
+}
+
+// Formats a value decoded from a JSON output into an HCL expression.
+// Objects and lists are written on several lines, indented by two spaces from `indent`.
+func formatHclValue(value interface{}, indent string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return quoteHclString(v), nil
+	case []interface{}:
+		if len(v) == 0 {
+			return "[]", nil
+		}
+
+		var sb strings.Builder
+		sb.WriteString("[\n")
+		for _, item := range v {
+			formattedItem, err := formatHclValue(item, indent+"  ")
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(fmt.Sprintf("%s  %s,\n", indent, formattedItem))
+		}
+		sb.WriteString(indent + "]")
+
+		return sb.String(), nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+
+		var keys []string
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, key := range keys {
+			formattedValue, err := formatHclValue(v[key], indent+"  ")
+			if err != nil {
+				return "", err
+			}
+
+			if !hclIdentifierRegexp.MatchString(key) {
+				key = quoteHclString(key)
+			}
+			sb.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, key, formattedValue))
+		}
+		sb.WriteString(indent + "}")
+
+		return sb.String(), nil
+	default:
+		return "", fmt.Errorf("type %T not supported", value)
+	}
+}
+
+// Formats a variable value for an environment variable: scalars are written as is
+// and other values (objects and lists) are encoded in JSON.
+func formatEnvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		encoded, err := json.Marshal(v)
+
+		return string(encoded), err
+	}
+}
+
+// Returns the variables names sorted, or an error if one of them is not a valid
+// environment variable name.
+func sortedEnvVarNames(vars map[string]interface{}) (varNames []string, err error) {
+	for varName := range vars {
+		if !envVarNameRegexp.MatchString(varName) {
+			return nil, fmt.Errorf("%s is not a valid environment variable name", varName)
+		}
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	return
+}
+
+// Writes a dotenv file: `NAME="value"`, with double quotes, backslashes, dollars and
+// backquotes escaped. New lines are kept inside the quotes, so that both dotenv parsers
+// and shells sourcing the file read them as new lines.
 func (i EnvFormat) Format() (input []byte, err error) {
+	varNames, err := sortedEnvVarNames(i)
+	if err != nil {
+		return
+	}
+
+	escaper := strings.NewReplacer(
+		"\\", "\\\\",
+		"\"", "\\\"",
+		"$", "\\$",
+		"`", "\\`",
+	)
+
 	buf := new(bytes.Buffer)
-	for varName, varVal := range i {
-		buf.WriteString(fmt.Sprintf("%s=\"%v\"\n", varName, varVal))
+	for _, varName := range varNames {
+		var varVal string
+		varVal, err = formatEnvValue(i[varName])
+		if err != nil {
+			return nil, fmt.Errorf("unable to format %s: %v", varName, err)
+		}
+		buf.WriteString(fmt.Sprintf("%s=\"%s\"\n", varName, escaper.Replace(varVal)))
 	}
 
 	input = buf.Bytes()
@@ -45,9 +524,45 @@ func (i EnvFormat) Format() (input []byte, err error) {
 	return
 }
 
-func (i EnvFormat) Environ() (envVars []string) {
-	for varName, varVal := range i {
-		envVars = append(envVars, fmt.Sprintf("%s=%v", varName, varVal))
+// Returns the variables as a list of `NAME=value`, suitable for a process environment.
+func (i EnvFormat) Environ() (envVars []string, err error) {
+	varNames, err := sortedEnvVarNames(i)
+	if err != nil {
+		return
 	}
+
+	for _, varName := range varNames {
+		var varVal string
+		varVal, err = formatEnvValue(i[varName])
+		if err != nil {
+			return nil, fmt.Errorf("unable to format %s: %v", varName, err)
+		}
+		envVars = append(envVars, fmt.Sprintf("%s=%s", varName, varVal))
+	}
+
+	return
+}
+
+// Writes a shell script exporting the variables: `export NAME='value'`.
+// Values are single quoted so that sourcing the file never interprets them.
+func (i ExportEnvFormat) Format() (input []byte, err error) {
+	varNames, err := sortedEnvVarNames(i)
+	if err != nil {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	for _, varName := range varNames {
+		var varVal string
+		varVal, err = formatEnvValue(i[varName])
+		if err != nil {
+			return nil, fmt.Errorf("unable to format %s: %v", varName, err)
+		}
+		buf.WriteString(fmt.Sprintf("export %s='%s'\n",
+			varName, strings.ReplaceAll(varVal, "'", `'\''`)))
+	}
+
+	input = buf.Bytes()
+
 	return
 }
@@ -0,0 +1,172 @@
+package infra
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+// Makes the XDG directories point to temporary ones for the duration of the test
+func useTemporaryXdgDirs(t *testing.T) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(dir, "state"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+}
+
+// Merges the values in the file as exeiac does before executing a module
+func merge(t *testing.T, formatter YamlMergeFormat) {
+	t.Helper()
+
+	data, err := formatter.Format()
+	if err != nil {
+		t.Fatalf("Format(): %v", err)
+	}
+	if err = formatter.SaveMerged(); err != nil {
+		t.Fatalf("SaveMerged(): %v", err)
+	}
+	if err = os.WriteFile(formatter.Path, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestYamlMergeFormatUnmerge(t *testing.T) {
+	tests := []struct {
+		name string
+		// The file content before the merge, nil if the file doesn't exist
+		initial []byte
+		values  []map[string]interface{}
+		// The file content once unmerged, nil if the file is expected not to exist
+		expected []byte
+	}{
+		{
+			name:     "replaced values are restored",
+			initial:  []byte("name: mine\nport: 80\nother: kept\n"),
+			values:   []map[string]interface{}{{"port": 8080, "name": "exeiac"}},
+			expected: []byte("name: mine\nport: 80\nother: kept\n"),
+		},
+		{
+			name:     "added keys are removed",
+			initial:  []byte("other: kept\n"),
+			values:   []map[string]interface{}{{"added": true}},
+			expected: []byte("other: kept\n"),
+		},
+		{
+			name:     "merged twice before being cleaned",
+			initial:  []byte("port: 80\n"),
+			values:   []map[string]interface{}{{"port": 8080}, {"port": 9090, "added": 1}},
+			expected: []byte("port: 80\n"),
+		},
+		{
+			name:     "non-string keys",
+			initial:  []byte("1: one\ntrue: kept\n"),
+			values:   []map[string]interface{}{{"1": "merged", "true": false}},
+			expected: []byte("1: one\ntrue: kept\n"),
+		},
+		{
+			name:     "file created by the merge",
+			initial:  nil,
+			values:   []map[string]interface{}{{"port": 8080}},
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useTemporaryXdgDirs(t)
+			path := filepath.Join(t.TempDir(), "values.yml")
+			if test.initial != nil {
+				if err := os.WriteFile(path, test.initial, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, values := range test.values {
+				merge(t, YamlMergeFormat{Path: path, Values: values})
+			}
+			if err := (YamlMergeFormat{Path: path, Values: test.values[0]}).Unmerge(); err != nil {
+				t.Fatalf("Unmerge(): %v", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if test.expected == nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("expected %s to be removed, got %q", path, content)
+				}
+
+				return
+			}
+			if string(content) != string(test.expected) {
+				t.Errorf("unmerged content = %q, expected %q", content, test.expected)
+			}
+		})
+	}
+}
+
+func TestYamlMergeFormatNonStringKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "values.yml")
+	if err := os.WriteFile(path, []byte("1: one\ntrue: kept\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := YamlMergeFormat{Path: path, Values: map[string]interface{}{"1": "merged", "true": false}}.Format()
+	if err != nil {
+		t.Fatalf("Format(): %v", err)
+	}
+	if string(data) != "1: merged\ntrue: false\n" {
+		t.Errorf("Format() = %q, expected the values of the existing keys to be replaced", data)
+	}
+}
+
+func TestYamlMergeFormatUnmergeWithoutMerge(t *testing.T) {
+	useTemporaryXdgDirs(t)
+	dir := t.TempDir()
+
+	// a missing file isn't created
+	missing := YamlMergeFormat{Path: filepath.Join(dir, "missing.yml"), Values: map[string]interface{}{"a": 1}}
+	if err := missing.Unmerge(); err != nil {
+		t.Fatalf("Unmerge(): %v", err)
+	}
+	if _, err := os.Stat(missing.Path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %s not to be created", missing.Path)
+	}
+
+	// a file exeiac hasn't merged in is left untouched
+	existing := YamlMergeFormat{Path: filepath.Join(dir, "existing.yml"), Values: map[string]interface{}{"a": 1}}
+	if err := os.WriteFile(existing.Path, []byte("a: 2\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := existing.Unmerge(); err != nil {
+		t.Fatalf("Unmerge(): %v", err)
+	}
+	if content, _ := os.ReadFile(existing.Path); string(content) != "a: 2\n" {
+		t.Errorf("expected %s to be left untouched, got %q", existing.Path, content)
+	}
+}
+
+func TestEnvFormatSourced(t *testing.T) {
+	value := "multi\nline \"quoted\" $HOME `id` \\n\r\nend"
+	data, err := EnvFormat{"VALUE": value}.Format()
+	if err != nil {
+		t.Fatalf("Format(): %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err = os.WriteFile(path, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	sourced, err := exec.Command("sh", "-c", `. "$0" && printf %s "$VALUE"`, path).Output()
+	if err != nil {
+		t.Fatalf("unable to source %q: %v", data, err)
+	}
+	if string(sourced) != value {
+		t.Errorf("sourced value = %q, expected %q", sourced, value)
+	}
+}
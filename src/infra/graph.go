@@ -0,0 +1,242 @@
+package infra
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// A dependency link between two elementary bricks.
+type Dependency struct {
+	// The brick whose output is needed
+	Previous *Brick
+	// The brick needing the output
+	Next *Brick
+	// The inputs of the `Next` brick creating the dependency
+	Inputs []Input
+}
+
+func (d Dependency) String() string {
+	return fmt.Sprintf("%s -> %s", d.Next.Name, d.Previous.Name)
+}
+
+// An index of the elementary bricks dependency graph, allowing to walk it both ways
+// without browsing every brick of the infra.
+type dependencyIndex struct {
+	// The direct previous bricks of each elementary brick, sorted by index
+	directPrevious map[*Brick]Bricks
+	// The direct next bricks of each elementary brick, sorted by index
+	directNext map[*Brick]Bricks
+}
+
+// Returns the dependencies of an elementary brick toward the elementary bricks it directly
+// depends on, sorted by the previous brick's index.
+// Inputs coming from a super-brick create a dependency toward each of its elementary bricks.
+func (infra *Infra) GetDependencies(brick *Brick) (dependencies []Dependency) {
+	positions := make(map[*Brick]int)
+
+	for _, input := range brick.Inputs {
+		var previousBricks Bricks
+		if input.Brick == nil {
+			continue
+		} else if input.Brick.IsElementary {
+			previousBricks = Bricks{input.Brick}
+		} else {
+			previousBricks = infra.subBricks(input.Brick)
+		}
+
+		for _, previous := range previousBricks {
+			if i, exist := positions[previous]; exist {
+				dependencies[i].Inputs = append(dependencies[i].Inputs, input)
+			} else {
+				positions[previous] = len(dependencies)
+				dependencies = append(dependencies, Dependency{
+					Previous: previous,
+					Next:     brick,
+					Inputs:   []Input{input},
+				})
+			}
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		return dependencies[i].Previous.Index < dependencies[j].Previous.Index
+	})
+
+	return
+}
+
+// Builds the index of the dependency graph of all elementary bricks.
+func (infra *Infra) buildDependencyIndex() *dependencyIndex {
+	index := dependencyIndex{
+		directPrevious: make(map[*Brick]Bricks),
+		directNext:     make(map[*Brick]Bricks),
+	}
+
+	var bricks Bricks
+	for _, b := range infra.Bricks {
+		if b.IsElementary {
+			bricks = append(bricks, b)
+		}
+	}
+	// NOTE(half-shell): browsing bricks by index keeps every list of the index sorted
+	sort.Sort(bricks)
+
+	for _, b := range bricks {
+		for _, d := range infra.GetDependencies(b) {
+			index.directPrevious[b] = append(index.directPrevious[b], d.Previous)
+			index.directNext[d.Previous] = append(index.directNext[d.Previous], b)
+		}
+	}
+
+	return &index
+}
+
+// Returns the dependency graph index, building it if bricks haven't been enriched yet.
+func (infra *Infra) getDependencyIndex() *dependencyIndex {
+	if infra.dependencyIndex == nil {
+		infra.dependencyIndex = infra.buildDependencyIndex()
+	}
+
+	return infra.dependencyIndex
+}
+
+// Returns all the bricks linked to the given one, by following the direct previous
+// bricks if `backward` is true, or the direct next ones otherwise.
+// Each brick is visited once. The result is sorted by index and doesn't contain the given
+// brick unless it depends on itself.
+func (index *dependencyIndex) walk(brick *Brick, backward bool) (linked Bricks) {
+	links := index.directNext
+	if backward {
+		links = index.directPrevious
+	}
+
+	visited := make(map[*Brick]bool)
+	toVisit := Bricks{brick}
+	for len(toVisit) > 0 {
+		b := toVisit[0]
+		toVisit = toVisit[1:]
+
+		for _, l := range links[b] {
+			if !visited[l] {
+				visited[l] = true
+				linked = append(linked, l)
+				toVisit = append(toVisit, l)
+			}
+		}
+	}
+	sort.Sort(linked)
+
+	return
+}
+
+// Returns the given bricks along with the first enrich error found among them.
+func (infra *Infra) getEnrichedBricks(bricks Bricks) (Bricks, error) {
+	for _, b := range bricks {
+		if b.EnrichError != nil {
+			return bricks, b.EnrichError
+		}
+	}
+
+	return bricks, nil
+}
+
+// Walks the dependency graph of the elementary bricks looking for a circular dependency.
+// Returns the dependencies forming the first cycle found, or nil if there is none.
+func (infra *Infra) findCircularDependency() []Dependency {
+	const (
+		notVisited = iota
+		inProgress
+		visited
+	)
+
+	var bricks Bricks
+	for _, b := range infra.Bricks {
+		if b.IsElementary && b.EnrichError == nil {
+			bricks = append(bricks, b)
+		}
+	}
+	sort.Sort(bricks)
+
+	states := make(map[*Brick]int)
+	// the dependencies followed from the brick the walk has started with
+	var path []Dependency
+
+	var visit func(brick *Brick) []Dependency
+	visit = func(brick *Brick) []Dependency {
+		states[brick] = inProgress
+
+		for _, d := range infra.GetDependencies(brick) {
+			if d.Previous.EnrichError != nil {
+				continue
+			}
+
+			switch states[d.Previous] {
+			case inProgress:
+				// the cycle starts where the previous brick has been entered,
+				// or is a brick depending on itself
+				start := len(path)
+				for i, p := range path {
+					if p.Next == d.Previous {
+						start = i
+						break
+					}
+				}
+
+				return append(append([]Dependency{}, path[start:]...), d)
+			case notVisited:
+				path = append(path, d)
+				if cycle := visit(d.Previous); cycle != nil {
+					return cycle
+				}
+				path = path[:len(path)-1]
+			}
+		}
+
+		states[brick] = visited
+
+		return nil
+	}
+
+	for _, b := range bricks {
+		if states[b] == notVisited {
+			if cycle := visit(b); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks the dependency graph of the elementary bricks.
+// Returns an `ErrCircularDependency` if some bricks depend on each other.
+// Displays a warning for each brick depending on a brick of the same room with a
+// higher index, since it doesn't match the order given by the bricks prefixes.
+func (infra *Infra) validateDependencies() error {
+	if cycle := infra.findCircularDependency(); cycle != nil {
+		return ErrCircularDependency{Cycle: cycle}
+	}
+
+	var bricks Bricks
+	for _, b := range infra.Bricks {
+		if b.IsElementary && b.EnrichError == nil {
+			bricks = append(bricks, b)
+		}
+	}
+	sort.Sort(bricks)
+
+	for _, b := range bricks {
+		for _, d := range infra.GetDependencies(b) {
+			// NOTE(half-shell): rooms ordering is arbitrary so indexes can only be
+			// compared inside a room
+			if d.Previous.Room == b.Room && d.Previous.Index > b.Index {
+				fmt.Fprintf(os.Stderr,
+					"Warning: brick %s depends on %s that comes after it (%s)\n",
+					b.Name, d.Previous.Name, d.Inputs[0].Origin())
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,279 @@
+package infra
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Builds an infra out of bricks given in index order. Elementary bricks are declared as
+// "name:previous1,previous2", super-bricks as "name/" (their sub-bricks are the elementary
+// bricks whose name starts with it).
+func newTestInfra(t *testing.T, declarations ...string) *Infra {
+	t.Helper()
+
+	infra := Infra{Bricks: make(BricksMap)}
+	dependencies := make(map[*Brick][]string)
+	for index, declaration := range declarations {
+		b := Brick{Index: index}
+		if strings.HasSuffix(declaration, "/") {
+			b.Name = strings.TrimSuffix(declaration, "/")
+		} else {
+			var previous string
+			b.Name, previous, _ = strings.Cut(declaration, ":")
+			b.IsElementary = true
+			for previous != "" {
+				var p string
+				p, previous, _ = strings.Cut(previous, ",")
+				dependencies[&b] = append(dependencies[&b], p)
+			}
+		}
+		b.Path = "/infra/" + b.Name + "/"
+		infra.Bricks[b.Name] = &b
+	}
+
+	for b, names := range dependencies {
+		for _, name := range names {
+			previous, exist := infra.Bricks[name]
+			if !exist {
+				t.Fatalf("brick %s depends on %s that isn't declared", b.Name, name)
+			}
+			b.Inputs = append(b.Inputs, Input{VarName: "from_" + name, Brick: previous, JsonPath: "$"})
+		}
+	}
+
+	return &infra
+}
+
+// Returns the declarations of a chain of `length` bricks, each one depending on the previous
+func chainDeclarations(length int) (declarations []string) {
+	declarations = append(declarations, "b0")
+	for i := 1; i < length; i++ {
+		declarations = append(declarations, fmt.Sprintf("b%d:b%d", i, i-1))
+	}
+
+	return
+}
+
+func chainNames(from int, to int) (names []string) {
+	for i := from; i < to; i++ {
+		names = append(names, fmt.Sprintf("b%d", i))
+	}
+
+	return
+}
+
+func names(bricks Bricks) []string {
+	result := []string{}
+	for _, b := range bricks {
+		result = append(result, b.Name)
+	}
+
+	return result
+}
+
+func TestDependencyGraph(t *testing.T) {
+	diamond := []string{"a", "b:a", "c:a", "d:b,c"}
+	superBrick := []string{"s/", "s/x", "s/y:s/x", "z:s", "w:z"}
+	cycle := []string{"a:c", "b:a", "c:b", "d:a"}
+
+	tests := []struct {
+		name         string
+		declarations []string
+		brick        string
+		// The names of the bricks expected from each function
+		directPrevious []string
+		linkedPrevious []string
+		directNext     []string
+		linkedNext     []string
+	}{
+		{
+			name:           "diamond bottom",
+			declarations:   diamond,
+			brick:          "d",
+			directPrevious: []string{"b", "c"},
+			linkedPrevious: []string{"a", "b", "c"},
+			directNext:     []string{},
+			linkedNext:     []string{},
+		},
+		{
+			name:           "diamond top",
+			declarations:   diamond,
+			brick:          "a",
+			directPrevious: []string{},
+			linkedPrevious: []string{},
+			directNext:     []string{"b", "c"},
+			linkedNext:     []string{"b", "c", "d"},
+		},
+		{
+			name:           "diamond side",
+			declarations:   diamond,
+			brick:          "b",
+			directPrevious: []string{"a"},
+			linkedPrevious: []string{"a"},
+			directNext:     []string{"d"},
+			linkedNext:     []string{"d"},
+		},
+		{
+			name:           "deep chain head",
+			declarations:   chainDeclarations(200),
+			brick:          "b0",
+			directPrevious: []string{},
+			linkedPrevious: []string{},
+			directNext:     []string{"b1"},
+			linkedNext:     chainNames(1, 200),
+		},
+		{
+			name:           "deep chain middle",
+			declarations:   chainDeclarations(200),
+			brick:          "b100",
+			directPrevious: []string{"b99"},
+			linkedPrevious: chainNames(0, 100),
+			directNext:     []string{"b101"},
+			linkedNext:     chainNames(101, 200),
+		},
+		{
+			name:           "super-brick input",
+			declarations:   superBrick,
+			brick:          "z",
+			directPrevious: []string{"s/x", "s/y"},
+			linkedPrevious: []string{"s/x", "s/y"},
+			directNext:     []string{"w"},
+			linkedNext:     []string{"w"},
+		},
+		{
+			name:           "sub-brick of a super-brick input",
+			declarations:   superBrick,
+			brick:          "s/x",
+			directPrevious: []string{},
+			linkedPrevious: []string{},
+			directNext:     []string{"s/y", "z"},
+			linkedNext:     []string{"s/y", "z", "w"},
+		},
+		{
+			name:           "cycle",
+			declarations:   cycle,
+			brick:          "a",
+			directPrevious: []string{"c"},
+			linkedPrevious: []string{"a", "b", "c"},
+			directNext:     []string{"b", "d"},
+			linkedNext:     []string{"a", "b", "c", "d"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := newTestInfra(t, test.declarations...)
+			brick := infra.Bricks[test.brick]
+
+			functions := []struct {
+				name     string
+				function func(*Brick) (Bricks, error)
+				expected []string
+			}{
+				{"GetDirectPrevious", infra.GetDirectPrevious, test.directPrevious},
+				{"GetLinkedPrevious", infra.GetLinkedPrevious, test.linkedPrevious},
+				{"GetDirectNext", infra.GetDirectNext, test.directNext},
+				{"GetLinkedNext", infra.GetLinkedNext, test.linkedNext},
+			}
+			for _, f := range functions {
+				bricks, err := f.function(brick)
+				if err != nil {
+					t.Fatalf("%s(%s): unexpected error: %v", f.name, test.brick, err)
+				}
+				if got := names(bricks); !reflect.DeepEqual(got, f.expected) {
+					t.Errorf("%s(%s) = %v, expected %v", f.name, test.brick, got, f.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLinkedNextEnrichError(t *testing.T) {
+	infra := newTestInfra(t, "a", "b:a", "c:b")
+	infra.Bricks["c"].EnrichError = fmt.Errorf("broken")
+
+	if _, err := infra.GetLinkedNext(infra.Bricks["a"]); err == nil {
+		t.Errorf("GetLinkedNext(a): expected the enrich error of c")
+	}
+	if _, err := infra.GetDirectNext(infra.Bricks["a"]); err != nil {
+		t.Errorf("GetDirectNext(a): unexpected error: %v", err)
+	}
+}
+
+func TestFindCircularDependency(t *testing.T) {
+	tests := []struct {
+		name         string
+		declarations []string
+		// The dependencies of the cycle found, as "next -> previous"
+		expected []string
+	}{
+		{
+			name:         "diamond",
+			declarations: []string{"a", "b:a", "c:a", "d:b,c"},
+			expected:     nil,
+		},
+		{
+			name:         "deep chain",
+			declarations: chainDeclarations(200),
+			expected:     nil,
+		},
+		{
+			name:         "super-brick input",
+			declarations: []string{"s/", "s/x", "s/y:s/x", "z:s"},
+			expected:     nil,
+		},
+		{
+			name:         "brick depending on itself",
+			declarations: []string{"a:a"},
+			expected:     []string{"a -> a"},
+		},
+		{
+			name:         "cycle",
+			declarations: []string{"a:c", "b:a", "c:b", "d:a"},
+			expected:     []string{"a -> c", "c -> b", "b -> a"},
+		},
+		{
+			name:         "cycle after a chain",
+			declarations: []string{"a", "b:a,d", "c:b", "d:c"},
+			expected:     []string{"b -> d", "d -> c", "c -> b"},
+		},
+		{
+			name:         "cycle through a super-brick",
+			declarations: []string{"s/", "s/x", "s/y:z", "z:s"},
+			expected:     []string{"s/y -> z", "z -> s/y"},
+		},
+		{
+			name:         "deep cycle",
+			declarations: append([]string{"b0:b199"}, chainDeclarations(200)[1:]...),
+			expected: func() (cycle []string) {
+				cycle = append(cycle, "b0 -> b199")
+				for i := 199; i > 0; i-- {
+					cycle = append(cycle, fmt.Sprintf("b%d -> b%d", i, i-1))
+				}
+
+				return
+			}(),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			infra := newTestInfra(t, test.declarations...)
+
+			var got []string
+			for _, d := range infra.findCircularDependency() {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("findCircularDependency() = %v, expected %v", got, test.expected)
+			}
+
+			err := infra.validateDependencies()
+			if _, isCircular := err.(ErrCircularDependency); isCircular != (test.expected != nil) {
+				t.Errorf("validateDependencies() = %v", err)
+			}
+		})
+	}
+}
@@ -13,23 +13,42 @@ import (
 	extools "src/exeiac/tools"
 	"strings"
 	"sync"
+	"time"
 )
 
 const BRICK_FILE_NAME = "brick.yml"
 
+// The maximum number of modules executed at the same time to describe the modules or to
+// fetch the outputs of bricks
+const MAX_CONCURRENT_FETCHES = 8
+
+// The key of the timeout applying to the actions that don't have their own
+const TIMEOUT_DEFAULT = "default"
+
 type Infra struct {
-	Modules []Module
+	Modules []*Module
 	Bricks  BricksMap
+	// The absolute path of exeiac's configuration file the infra comes from, if any
+	ConfigurationFile string
+	// The timeouts of the modules actions from exeiac's configuration, by action name
+	Timeouts map[string]time.Duration
+	// The timeout of every module execution, overriding all others if not 0
+	Timeout time.Duration
+	// The elementary bricks dependency graph. Built once bricks are enriched.
+	dependencyIndex *dependencyIndex
 }
 
 func CreateInfra(configuration exargs.Configuration) (Infra, error) {
 	i := Infra{
-		Bricks: make(map[string]*Brick),
+		Bricks:            make(map[string]*Brick),
+		ConfigurationFile: configuration.ConfigurationFile,
+		Timeouts:          configuration.Timeouts,
+		Timeout:           configuration.Timeout,
 	}
 
 	// create Modules
 	for name, path := range configuration.Modules {
-		i.Modules = append(i.Modules, Module{
+		i.Modules = append(i.Modules, &Module{
 			Name: name,
 			Path: path,
 		})
@@ -165,17 +184,38 @@ func (infra Infra) String() string {
 }
 
 func (infra *Infra) GetModule(name string, b *Brick) (*Module, error) {
-	for i, m := range infra.Modules {
+	for _, m := range infra.Modules {
 		if m.Name == name {
-			return &(infra.Modules[i]), nil
+			return m, nil
 		}
 	}
+	// NOTE(half-shell): built-in modules can be used without being declared in the
+	// configuration; the driver is checked when the module is described
+	if strings.HasPrefix(name, BUILTIN_MODULE_PREFIX) {
+		m := &Module{
+			Name: name,
+			Path: name,
+		}
+		infra.Modules = append(infra.Modules, m)
+
+		return m, nil
+	}
 	if strings.HasPrefix(name, "./") {
-		infra.Modules = append(infra.Modules, Module{
+		// NOTE(half-shell): bricks sharing a local module share it, to only describe it once
+		path := filepath.Join(b.Path, name)
+		for _, m := range infra.Modules {
+			if m.Path == path {
+				return m, nil
+			}
+		}
+
+		m := &Module{
 			Name: b.Name,
-			Path: b.Path + "/" + strings.TrimPrefix(name, "./"),
-		})
-		return &(infra.Modules[len(infra.Modules)-1:][0]), nil
+			Path: path,
+		}
+		infra.Modules = append(infra.Modules, m)
+
+		return m, nil
 	}
 
 	return nil, errors.New("No matching module name")
@@ -185,139 +225,77 @@ func (infra *Infra) GetModule(name string, b *Brick) (*Module, error) {
 // If the provided brick is an elementary one, we just return a slice
 // of length 1 with that brick.
 func (i *Infra) GetSubBricks(brick *Brick) (subBricks Bricks, err error) {
+	for _, b := range i.subBricks(brick) {
+		if b.EnrichError != nil {
+			err = b.EnrichError
+			return
+		}
+
+		subBricks = append(subBricks, b)
+	}
+
+	return
+}
+
+// Returns all the elementary bricks of a brick sorted by index,
+// regardless of their enrich errors.
+func (i *Infra) subBricks(brick *Brick) (subBricks Bricks) {
 	// the infra.Bricks is sorted with super bricks
 	// directly before their subbricks
 	superBrickPath := brick.Path
 	for _, b := range i.Bricks {
 		// We ignore the sub-brick if they're the same by checking if they have a common prefix
 		if b.IsElementary && strings.HasPrefix(b.Path, superBrickPath) {
-			if b.EnrichError != nil {
-				err = b.EnrichError
-				return
-			}
-
-			subBricks = append(subBricks, i.Bricks[b.Name])
+			subBricks = append(subBricks, b)
 		}
 	}
+	sort.Sort(subBricks)
 
 	return
 }
 
-// Return only elementary bricks (although Brick.DirectPrevious can contains super brick)
-// return error when a elementary bricks b to return has b.EnrichError != nil
-func (infra *Infra) GetDirectPrevious(brick *Brick) (results Bricks, err error) {
-	for _, dp := range brick.DirectPrevious {
-		err = dp.EnrichError
-		if dp.EnrichError != nil {
-			return
-		}
+// Returns the super-bricks containing the given brick, from its room to its direct parent.
+func (infra *Infra) GetSuperBricks(brick *Brick) (superBricks Bricks) {
+	if brick.Room == brick {
+		return
+	}
 
-		if dp.IsElementary {
-			results = append(results, dp)
-		} else {
-			var elementaryBricks Bricks
-			elementaryBricks, err = infra.GetSubBricks(dp)
-			if err != nil {
-				return
-			}
-			results = append(results, elementaryBricks...)
+	name := brick.Name
+	for strings.Contains(name, "/") {
+		name = name[:strings.LastIndex(name, "/")]
+		if b, exist := infra.Bricks[name]; exist {
+			superBricks = append(Bricks{b}, superBricks...)
+		}
+		if name == brick.Room.Name {
+			break
 		}
 	}
 
 	return
 }
 
+// Return only elementary bricks (although Brick.DirectPrevious can contains super brick)
+// return error when a elementary bricks b to return has b.EnrichError != nil
+func (infra *Infra) GetDirectPrevious(brick *Brick) (results Bricks, err error) {
+	return infra.getEnrichedBricks(infra.getDependencyIndex().directPrevious[brick])
+}
+
 // Return only elementary bricks,
 // return error when a elementary bricks b to return has b.EnrichError != nil
 func (infra *Infra) GetLinkedPrevious(brick *Brick) (results Bricks, err error) {
-	var directPrevious Bricks
-	added, err := infra.GetDirectPrevious(brick)
-	if err != nil {
-		return
-	}
-	results = added
-	for {
-		toAdd := Bricks{}
-		for _, b := range added {
-			directPrevious, err = infra.GetDirectPrevious(b)
-			for _, dp := range directPrevious {
-				if !results.BricksContains(dp) {
-					toAdd = append(toAdd, dp)
-				}
-			}
-		}
-		if len(toAdd) == 0 {
-			break
-		}
-		results = append(results, toAdd...)
-		added = toAdd
-	}
-	return
+	return infra.getEnrichedBricks(infra.getDependencyIndex().walk(brick, true))
 }
 
 // Checks wheither or not a the brick that dependends directly of the given brick are enriched.
 // Returns a slice of brick's pointers if they are, or the first error encountered otherwise
 func (infra *Infra) GetDirectNext(brick *Brick) (results Bricks, err error) {
-	// browse all infra.Bricks and return bricks that have brick in their directPrevious bricks
-	for _, b := range infra.Bricks {
-		err = b.EnrichError
-		if err != nil {
-			return
-		}
-
-		var directPrevious Bricks
-		directPrevious, err = infra.GetDirectPrevious(b)
-		if err != nil {
-			return
-		}
-		for _, dp := range directPrevious {
-			if dp.Name == brick.Name {
-				results = append(results, infra.Bricks[b.Name])
-			}
-		}
-	}
-	return
+	return infra.getEnrichedBricks(infra.getDependencyIndex().directNext[brick])
 }
 
 // Cheks wheither or not the brick that dependends (directly or not) of the given brick are enriched.
 // Returns a slice of brick's pointers if they are, or the first error encountered otherwise
-func (infra *Infra) GetLinkedNext(bricks *Bricks, brick *Brick) (err error) {
-	var wg sync.WaitGroup
-
-	for _, b := range infra.Bricks {
-		err = b.EnrichError
-		if err != nil {
-			return
-		}
-		var directPrevious Bricks
-		directPrevious, err = infra.GetDirectPrevious(b)
-		if err != nil {
-			return
-		}
-		for _, dp := range directPrevious {
-			if dp.Name == brick.Name {
-				*bricks = append(*bricks, infra.Bricks[b.Name])
-
-				// TODO(half-shell): handle cases of cirulare dependencies
-				// or misconfiguration. We want to add a check to either limit
-				// the number of wait group created, or just a check over the indexes
-				// of the bricks handled here. Or prevent adding a brick already added
-				// to avoid those cases.
-				if len(b.DirectPrevious) > 0 {
-					wg.Add(1)
-
-					go func(bricks *Bricks, brick *Brick) {
-						defer wg.Done()
-						infra.GetLinkedNext(bricks, brick)
-					}(bricks, b)
-				}
-			}
-		}
-	}
-
-	wg.Wait()
-
-	return
+func (infra *Infra) GetLinkedNext(brick *Brick) (results Bricks, err error) {
+	return infra.getEnrichedBricks(infra.getDependencyIndex().walk(brick, false))
 }
 
 func (infra *Infra) GetBricksFromNames(names []string) (bricks Bricks, err error) {
@@ -368,19 +346,19 @@ func (infra *Infra) GetCorrespondingBricks(
 				bricksToAdd = append(bricksToAdd, bs...)
 			}
 		case "selected", "s":
+			// NOTE(half-shell): the bricks in error are kept to report their enrich error below
 			for _, brick := range elementaryBricks {
-				bs, _ := infra.GetSubBricks(brick)
-				bricksToAdd = append(bricksToAdd, bs...)
+				bricksToAdd = append(bricksToAdd, infra.subBricks(brick)...)
 			}
 		case "direct_next", "dn":
 			for _, brick := range elementaryBricks {
 				bs, _ := infra.GetDirectNext(brick)
 				bricksToAdd = append(bricksToAdd, bs...)
 			}
-		case "linked_next", "all_next", "ln", "an":
+		case "linked_next", "all_next", "ln", "an",
+			"needed_next", "needed_dependents", "nn":
 			for _, brick := range elementaryBricks {
-				var bs Bricks
-				infra.GetLinkedNext(&bs, brick)
+				bs, _ := infra.GetLinkedNext(brick)
 				bricksToAdd = append(bricksToAdd, bs...)
 			}
 		default:
@@ -410,7 +388,11 @@ func (infra *Infra) GetCorrespondingBricks(
 	return
 }
 
-func (infra *Infra) EnrichBricks() {
+// Enriches every elementary brick with its `brick.yml` configuration file, describes the
+// modules they use, then checks the dependency graph of the bricks.
+// Errors concerning a single brick are kept in its `EnrichError`.
+// Returns an error if the dependency graph is not valid (e.g. circular dependencies).
+func (infra *Infra) EnrichBricks() error {
 	for _, b := range infra.Bricks {
 		if b.IsElementary {
 			conf, err := BrickConfYaml{}.New(b.ConfigurationFilePath)
@@ -424,14 +406,65 @@ func (infra *Infra) EnrichBricks() {
 				infra.Bricks[b.Name].EnrichError =
 					fmt.Errorf("unable to enrich brick(%s): %v", b.Name, err)
 			}
+		}
+	}
 
-			err = b.Module.LoadAvailableActions()
-			if err != nil {
-				infra.Bricks[b.Name].EnrichError =
-					fmt.Errorf("unable to enrich brick(%s): %v", b.Name, err)
+	moduleErrors := infra.describeModules()
+	for _, b := range infra.Bricks {
+		if !b.IsElementary || b.Module == nil {
+			continue
+		}
+
+		err := moduleErrors[b.Module]
+		if err == nil {
+			err = b.Module.Validate()
+		}
+		if err != nil {
+			infra.Bricks[b.Name].EnrichError =
+				fmt.Errorf("unable to enrich brick(%s): %v", b.Name, err)
+		} else {
+			b.Module.warnAboutInputFormats(b)
+		}
+	}
+
+	infra.dependencyIndex = infra.buildDependencyIndex()
+
+	return infra.validateDependencies()
+}
+
+// Describes the modules used by the elementary bricks, at most MAX_CONCURRENT_FETCHES at
+// the same time. Returns the errors encountered by module.
+func (infra *Infra) describeModules() map[*Module]error {
+	var modules []*Module
+	for _, m := range infra.Modules {
+		for _, b := range infra.Bricks {
+			if b.IsElementary && b.Module == m {
+				modules = append(modules, m)
+				break
 			}
 		}
 	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[*Module]error)
+	workers := make(chan struct{}, MAX_CONCURRENT_FETCHES)
+	for _, m := range modules {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(m *Module) {
+			defer wg.Done()
+			err := m.LoadDescription()
+			<-workers
+
+			mutex.Lock()
+			errs[m] = err
+			mutex.Unlock()
+		}(m)
+	}
+	wg.Wait()
+
+	return errs
 }
 
 func (infra *Infra) ValidateConfiguration(configuration *exargs.Configuration) (err error) {
@@ -448,6 +481,11 @@ func (infra *Infra) ValidateConfiguration(configuration *exargs.Configuration) (
 			return ErrBadArg{Reason: "Brick's specifier doesn't exist:", Value: specifier}
 		}
 	}
+
+	// validate ReportFormat
+	if !extools.ContainsString(exargs.AvailableReportFormats[:], configuration.ReportFormat) {
+		return ErrBadArg{Reason: "Report format doesn't exist", Value: configuration.ReportFormat}
+	}
 	return nil
 }
 
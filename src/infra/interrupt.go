@@ -0,0 +1,96 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	exstatuscode "src/exeiac/statuscode"
+	extools "src/exeiac/tools"
+)
+
+var interruption = struct {
+	mutex sync.Mutex
+	// Cancelled on the first SIGINT or SIGTERM received
+	ctx    context.Context
+	cancel context.CancelFunc
+	// The first signal received, nil if exeiac hasn't been interrupted
+	signal os.Signal
+	// Closed on the second signal received, to kill the modules still running
+	kill chan struct{}
+	// The number of confirmations being asked
+	prompting int
+}{kill: make(chan struct{})}
+
+func init() {
+	interruption.ctx, interruption.cancel = context.WithCancel(context.Background())
+}
+
+// Catches SIGINT and SIGTERM for the whole exeiac execution.
+// While a confirmation is asked, nothing has been done yet so exeiac exits right away.
+// Otherwise, on the first signal, the running modules are asked to stop by forwarding them
+// the signal, and no module is started anymore so that actions can report what has been
+// interrupted and save their state.
+// On the second one, the running modules are killed. A third one exits right away.
+func HandleInterruptions() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for sig := range signals {
+			interruption.mutex.Lock()
+			if interruption.prompting > 0 {
+				fmt.Fprintf(os.Stderr, "\nReceived %v: exiting\n", sig)
+				os.Exit(exstatuscode.RUN_ERROR)
+			}
+			if interruption.signal == nil {
+				interruption.signal = sig
+				interruption.mutex.Unlock()
+				fmt.Fprintf(os.Stderr, "\nReceived %v: waiting for the running modules to stop "+
+					"(send it again to kill them)\n", sig)
+				interruption.cancel()
+
+				continue
+			}
+			interruption.mutex.Unlock()
+
+			fmt.Fprintf(os.Stderr, "\nReceived %v again: killing the running modules\n", sig)
+			close(interruption.kill)
+			signal.Stop(signals)
+
+			return
+		}
+	}()
+}
+
+// Asks a confirmation to the human, see `extools.AskConfirmation`. exeiac exits right away
+// if it is interrupted meanwhile.
+func AskConfirmation(question string) (bool, error) {
+	interruption.mutex.Lock()
+	interruption.prompting++
+	interruption.mutex.Unlock()
+
+	defer func() {
+		interruption.mutex.Lock()
+		interruption.prompting--
+		interruption.mutex.Unlock()
+	}()
+
+	return extools.AskConfirmation(question)
+}
+
+// Returns the signal that has interrupted exeiac, or nil if it hasn't been interrupted
+func InterruptionSignal() os.Signal {
+	interruption.mutex.Lock()
+	defer interruption.mutex.Unlock()
+
+	return interruption.signal
+}
+
+// Returns wheither or not exeiac has been interrupted by SIGINT or SIGTERM
+func IsInterrupted() bool {
+	return InterruptionSignal() != nil
+}
@@ -8,6 +8,8 @@ import (
 	"os/exec"
 	extools "src/exeiac/tools"
 	"strings"
+	"syscall"
+	"time"
 )
 
 const ACTION_HELP = "help"
@@ -18,10 +20,28 @@ const ACTION_PLAN = "plan"
 const ACTION_REMOVE = "remove"
 const ACTION_SHOW_AVAILABLE_ACTIONS = "show_implemented_actions"
 
+// The delay given to a timed out module to stop before being killed
+const KILL_DELAY = 10 * time.Second
+
 type Module struct {
 	Name    string
 	Path    string
 	Actions []string
+	// The version of the protocol the module implements, see ACTION_DESCRIBE
+	ProtocolVersion int
+	// The actions that don't change anything
+	ReadOnlyActions []string
+	// The actions accepting the --non-interactive option
+	NonInteractiveActions []string
+	// The executables the module needs
+	RequiredTools []string
+	// The input formats the module prefers to read, by order of preference
+	InputFormats []string
+	// The driver executing the module's actions in exeiac's process, nil if the module
+	// is an executable
+	Driver Driver
+	// Wheither or not the module has already been described
+	described bool
 }
 
 func (m Module) String() string {
@@ -30,6 +50,13 @@ func (m Module) String() string {
 	sb.WriteString(fmt.Sprintf("-\tName: %s\n", m.Name))
 	sb.WriteString(fmt.Sprintf("\tPath: %s\n", m.Path))
 	sb.WriteString(fmt.Sprintf("\tActions: %v\n", m.Actions))
+	if m.ProtocolVersion != LEGACY_PROTOCOL_VERSION {
+		sb.WriteString(fmt.Sprintf("\tProtocolVersion: %d\n", m.ProtocolVersion))
+		sb.WriteString(fmt.Sprintf("\tReadOnlyActions: %v\n", m.ReadOnlyActions))
+		sb.WriteString(fmt.Sprintf("\tNonInteractiveActions: %v\n", m.NonInteractiveActions))
+		sb.WriteString(fmt.Sprintf("\tRequiredTools: %v\n", m.RequiredTools))
+		sb.WriteString(fmt.Sprintf("\tInputFormats: %v\n", m.InputFormats))
+	}
 
 	return sb.String()
 }
@@ -72,8 +99,13 @@ func (module *Module) LoadAvailableActions() (err error) {
 	return
 }
 
+// Executes the module, stopping it if its action times out or if exeiac is interrupted.
+// A timed out module is asked to stop with SIGTERM, then killed after KILL_DELAY.
+// Returns an ExecTimeoutError or an ExecInterruptedError if the module has been stopped
+// and hasn't exited successfully.
 func (m *Module) exec(
 	brick *Brick,
+	action string,
 	args []string,
 	env []string,
 	stdout io.Writer,
@@ -81,19 +113,74 @@ func (m *Module) exec(
 ) (
 	err error,
 ) {
+	if sig := InterruptionSignal(); sig != nil {
+		return ExecInterruptedError{Action: action, Signal: sig}
+	}
+
 	cmd := exec.Cmd{
 		Path:   m.Path,
-		Args:   append([]string{m.Path}, args...),
+		Args:   append([]string{m.Path, action}, args...),
 		Env:    env,
 		Dir:    brick.Path,
 		Stdin:  os.Stdin,
 		Stdout: stdout,
 		Stderr: stderr,
 	}
+	setProcessGroup(&cmd)
 
-	err = cmd.Run()
+	err = cmd.Start()
+	if err != nil {
+		return
+	}
 
-	return
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeoutReached, killDelayReached <-chan time.Time
+	timeout := brick.Timeout(action)
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutReached = timer.C
+	}
+
+	interrupted := interruption.ctx.Done()
+	kill := interruption.kill
+	var stopErr error
+	for {
+		select {
+		case err = <-done:
+			if stopErr != nil && err != nil {
+				err = stopErr
+			}
+
+			return
+		case <-timeoutReached:
+			timeoutReached = nil
+			stopErr = ExecTimeoutError{Action: action, Timeout: timeout}
+			fmt.Fprintf(stderr, "%s of %s has timed out after %v: stopping it\n", action, brick.Name, timeout)
+			if signalModule(&cmd, syscall.SIGTERM) != nil {
+				killModule(&cmd)
+			}
+			killDelayReached = time.After(KILL_DELAY)
+		case <-interrupted:
+			interrupted = nil
+			if stopErr == nil {
+				stopErr = ExecInterruptedError{Action: action, Signal: InterruptionSignal()}
+			}
+			if signalModule(&cmd, InterruptionSignal()) != nil {
+				killModule(&cmd)
+			}
+		case <-kill:
+			kill = nil
+			killModule(&cmd)
+		case <-killDelayReached:
+			killDelayReached = nil
+			killModule(&cmd)
+		}
+	}
 }
 
 // Executes a module's action over a brick, the provided CLI arguments and environment
@@ -103,6 +190,8 @@ func (m *Module) exec(
 // Returns a statusCode returned by the module, and an error if any.
 // Note that `err` here is not an error thrown from the external module, but only coming
 // from the go execution. Module errors are displayed in `stderr`.
+// It is an ExecTimeoutError if the action has exceeded the brick's timeout, or an
+// ExecInterruptedError if exeiac has been interrupted.
 func (m *Module) Exec(
 	b *Brick,
 	action string,
@@ -132,12 +221,28 @@ func (m *Module) Exec(
 		env = append(env, confEnv...)
 	}
 
+	if !m.AcceptsNonInteractive(action) {
+		args = extools.StrSliceXor([]string{"--non-interactive"}, args)
+	}
+
+	if m.Driver != nil {
+		stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+		if len(writers) > 0 {
+			stdout = writers[0]
+		}
+		if len(writers) > 1 {
+			stderr = writers[1]
+		}
+
+		return m.execDriver(b, action, args, env, stdout, stderr)
+	}
+
 	if len(writers) > 1 {
-		err = m.exec(b, append([]string{action}, args...), env, writers[0], writers[1])
+		err = m.exec(b, action, args, env, writers[0], writers[1])
 	} else if len(writers) > 0 {
-		err = m.exec(b, append([]string{action}, args...), env, writers[0], os.Stderr)
+		err = m.exec(b, action, args, env, writers[0], os.Stderr)
 	} else {
-		err = m.exec(b, append([]string{action}, args...), env, os.Stdout, os.Stderr)
+		err = m.exec(b, action, args, env, os.Stdout, os.Stderr)
 	}
 
 	if err != nil {
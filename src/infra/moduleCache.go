@@ -0,0 +1,83 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+)
+
+// The directory, relative to the XDG cache directory, where modules descriptions are cached
+const MODULE_CACHE_DIR = "exeiac/modules"
+
+// A module description as stored in the cache
+type cachedModule struct {
+	Path string `json:"path"`
+	// The size and modification time of the module's executable when it was described
+	Size        int64             `json:"size"`
+	ModTime     int64             `json:"mod_time"`
+	Description moduleDescription `json:"description"`
+}
+
+func moduleCacheFilePath(path string) (string, error) {
+	hash := sha256.Sum256([]byte(path))
+
+	return xdg.CacheFile(filepath.Join(MODULE_CACHE_DIR, hex.EncodeToString(hash[:])+".json"))
+}
+
+// Looks for the description of the module's executable `path` in the cache.
+// Returns the description if it has been cached since the executable last changed.
+// NOTE(half-shell): only the executable itself is watched; a module that is a wrapper
+// around another script isn't described again when the wrapped script changes.
+func loadCachedModuleDescription(path string) (description moduleDescription, found bool) {
+	cachePath, err := moduleCacheFilePath(path)
+	if err != nil {
+		return
+	}
+
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		return
+	}
+
+	var cached cachedModule
+	if json.Unmarshal(content, &cached) != nil {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || cached.Path != path || cached.Size != info.Size() ||
+		cached.ModTime != info.ModTime().UnixNano() {
+		return
+	}
+
+	return cached.Description, true
+}
+
+// Stores the description of the module's executable `path` in the cache.
+func cacheModuleDescription(path string, description moduleDescription) error {
+	cachePath, err := moduleCacheFilePath(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(cachedModule{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime().UnixNano(),
+		Description: description,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cachePath, content, 0600)
+}
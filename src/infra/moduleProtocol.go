@@ -0,0 +1,182 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	extools "src/exeiac/tools"
+)
+
+const ACTION_DESCRIBE = "describe"
+
+// The version of the protocol of the modules that only implement ACTION_SHOW_AVAILABLE_ACTIONS
+const LEGACY_PROTOCOL_VERSION = 0
+
+// The latest version of the module protocol supported by exeiac
+const MODULE_PROTOCOL_VERSION = 1
+
+// What a module returns, as JSON, when ACTION_DESCRIBE is called
+type moduleDescription struct {
+	ProtocolVersion int `json:"protocol_version"`
+	// The actions implemented by the module
+	Actions []string `json:"actions"`
+	// The actions that don't change anything (e.g. plan, output)
+	ReadOnlyActions []string `json:"read_only_actions"`
+	// The actions accepting the --non-interactive option
+	NonInteractiveActions []string `json:"non_interactive_actions"`
+	// The executables the module needs to be found in the PATH
+	RequiredTools []string `json:"required_tools"`
+	// The input formats the module prefers to read, by order of preference. Bricks giving it
+	// another format only get a warning.
+	InputFormats []string `json:"input_formats"`
+}
+
+// Gets the module's protocol version, actions and requirements, from its driver if it is a
+// built-in module, from the cache if the module's executable hasn't changed since it was
+// last described, or by executing ACTION_DESCRIBE otherwise.
+// The module is only described once.
+func (module *Module) LoadDescription() (err error) {
+	if module.described {
+		return
+	}
+
+	module.Driver, err = getDriver(module.Path)
+	if err != nil {
+		return fmt.Errorf("unable to describe module %s: %v", module.Name, err)
+	}
+	if module.Driver != nil {
+		module.setDescription(describeDriver(module.Driver))
+
+		return
+	}
+
+	path, err := exec.LookPath(module.Path)
+	if err != nil {
+		return fmt.Errorf("unable to describe module %s: %v", module.Name, err)
+	}
+	if absolutePath, absErr := filepath.Abs(path); absErr == nil {
+		path = absolutePath
+	}
+
+	description, found := loadCachedModuleDescription(path)
+	if !found {
+		description, err = module.describe(path)
+		if err != nil {
+			return
+		}
+
+		if cacheErr := cacheModuleDescription(path, description); cacheErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: unable to cache the description of module %s: %v\n",
+				module.Name, cacheErr)
+		}
+	}
+
+	module.setDescription(description)
+
+	return
+}
+
+func (module *Module) setDescription(description moduleDescription) {
+	module.ProtocolVersion = description.ProtocolVersion
+	module.Actions = description.Actions
+	module.ReadOnlyActions = description.ReadOnlyActions
+	module.NonInteractiveActions = description.NonInteractiveActions
+	module.RequiredTools = description.RequiredTools
+	module.InputFormats = description.InputFormats
+	module.described = true
+}
+
+// Executes the ACTION_DESCRIBE command on the module's executable `path`. Modules that don't
+// implement it (i.e. that don't return a valid description) fall back on the legacy protocol,
+// see `LoadAvailableActions`.
+func (module *Module) describe(path string) (description moduleDescription, err error) {
+	// NOTE(half-shell): legacy modules usually complain about an unknown action;
+	// it isn't worth displaying it.
+	stdout := StoreStdout{}
+	cmd := exec.Cmd{
+		Path:   path,
+		Args:   []string{path, ACTION_DESCRIBE},
+		Stdout: &stdout,
+		Stderr: io.Discard,
+	}
+
+	if cmd.Run() == nil && json.Unmarshal(stdout.Output, &description) == nil &&
+		description.ProtocolVersion > LEGACY_PROTOCOL_VERSION {
+		return
+	}
+
+	err = module.LoadAvailableActions()
+
+	return moduleDescription{ProtocolVersion: LEGACY_PROTOCOL_VERSION, Actions: module.Actions}, err
+}
+
+// Checks that exeiac is able to use the module: that its protocol version is supported,
+// that its actions are consistent and that the tools it requires are installed.
+func (module *Module) Validate() error {
+	if module.ProtocolVersion > MODULE_PROTOCOL_VERSION {
+		return fmt.Errorf("module %s uses the protocol version %d but exeiac only supports up to %d",
+			module.Name, module.ProtocolVersion, MODULE_PROTOCOL_VERSION)
+	}
+
+	describedActions := append(append([]string{}, module.ReadOnlyActions...), module.NonInteractiveActions...)
+	var unknownActions []string
+	for _, action := range describedActions {
+		if !extools.ContainsString(module.Actions, action) {
+			unknownActions = append(unknownActions, action)
+		}
+	}
+	if len(unknownActions) > 0 {
+		return fmt.Errorf("module %s describes actions it doesn't implement: %v",
+			module.Name, extools.Deduplicate(unknownActions))
+	}
+
+	for _, format := range module.InputFormats {
+		if _, exist := SupportedFormats[format]; !exist {
+			return fmt.Errorf("module %s prefers an input format exeiac doesn't support: %s",
+				module.Name, format)
+		}
+	}
+
+	var missingTools []string
+	for _, tool := range module.RequiredTools {
+		if _, err := exec.LookPath(tool); err != nil {
+			missingTools = append(missingTools, tool)
+		}
+	}
+	if len(missingTools) > 0 {
+		return fmt.Errorf("module %s requires tools that are not installed: %v", module.Name, missingTools)
+	}
+
+	return nil
+}
+
+// Returns wheither or not the module declares that an action doesn't change anything.
+// Actions of modules using the legacy protocol are never seen as read-only.
+func (module *Module) IsReadOnly(action string) bool {
+	return extools.ContainsString(module.ReadOnlyActions, action)
+}
+
+// Returns wheither or not the --non-interactive option can be given to an action.
+// Modules using the legacy protocol are given it for every action.
+func (module *Module) AcceptsNonInteractive(action string) bool {
+	return module.ProtocolVersion == LEGACY_PROTOCOL_VERSION ||
+		extools.ContainsString(module.NonInteractiveActions, action)
+}
+
+// Warns about the inputs of a brick whose format isn't among the ones the module prefers,
+// if it has declared some. They are still written, since a module may read other formats.
+func (module *Module) warnAboutInputFormats(b *Brick) {
+	if len(module.InputFormats) == 0 {
+		return
+	}
+
+	for _, i := range b.Inputs {
+		if !extools.ContainsString(module.InputFormats, string(i.Format)) {
+			fmt.Fprintf(os.Stderr, "Warning: brick %s gives the %s format to module %s, "+
+				"which prefers %v (%s)\n", b.Name, i.Format, module.Name, module.InputFormats, i.Origin())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package infra
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Process groups aren't supported on this platform
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// Signals can't be sent on every platform (e.g. windows), the module is killed in this case.
+func signalModule(cmd *exec.Cmd, sig os.Signal) error {
+	if err := cmd.Process.Signal(sig); err == nil {
+		return nil
+	}
+
+	return cmd.Process.Kill()
+}
+
+func killModule(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,104 @@
+//go:build unix
+
+package infra
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/mattn/go-isatty"
+)
+
+// Starts the module in its own process group, so that a signal reaches every process it
+// has started (e.g. the providers of terraform).
+// NOTE(half-shell): when stdin is a terminal, the module stays in exeiac's process group
+// to be able to read it. The terminal already sends it SIGINT on Ctrl-C in this case, and
+// the other signals are sent to each of its descendants, see `signalProcessTree`.
+func setProcessGroup(cmd *exec.Cmd) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		return
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// Sends a signal to the module's process group, or to the module and its descendants if it
+// doesn't have its own process group.
+func signalModule(cmd *exec.Cmd, sig os.Signal) error {
+	s, isSyscallSignal := sig.(syscall.Signal)
+	if !isSyscallSignal {
+		s = syscall.SIGTERM
+	}
+
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		// NOTE(half-shell): the terminal has already sent SIGINT to the whole process group;
+		// sending it again would make some modules stop without cleaning up
+		if sig == os.Interrupt {
+			return nil
+		}
+
+		return signalProcessTree(cmd.Process.Pid, s)
+	}
+
+	return syscall.Kill(-cmd.Process.Pid, s)
+}
+
+// Kills the module's process group, or the module and its descendants if it doesn't have
+// its own process group.
+func killModule(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		return signalProcessTree(cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// Sends a signal to a process and to all its descendants. Returns the error of the signal
+// sent to the process itself; its descendants may have already exited.
+func signalProcessTree(pid int, sig syscall.Signal) error {
+	// NOTE(half-shell): descendants are listed before anything is signaled, since the ones
+	// whose parent exits are reparented and couldn't be found anymore
+	pids := descendants(pid)
+	err := syscall.Kill(pid, sig)
+	for _, p := range pids {
+		syscall.Kill(p, sig)
+	}
+
+	return err
+}
+
+// Returns the pids of the descendants of a process, listed with ps. Returns none if ps
+// fails.
+func descendants(pid int) (pids []int) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return
+	}
+
+	children := make(map[int][]int)
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		child, errChild := strconv.Atoi(string(fields[0]))
+		parent, errParent := strconv.Atoi(string(fields[1]))
+		if errChild != nil || errParent != nil {
+			continue
+		}
+		children[parent] = append(children[parent], child)
+	}
+
+	parents := []int{pid}
+	for len(parents) > 0 {
+		parent := parents[0]
+		parents = parents[1:]
+		pids = append(pids, children[parent]...)
+		parents = append(parents, children[parent]...)
+	}
+
+	return
+}
@@ -0,0 +1,105 @@
+//go:build unix
+
+package infra
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Writes a module starting a child process that writes its pid in `pidFile`, then waiting
+// for it.
+func writeSpawningModule(t *testing.T, pidFile string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "module.sh")
+	script := "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + "\nwait\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// Returns the pid written in `pidFile`, waiting for it to be written
+func readChildPid(t *testing.T, pidFile string) int {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		content, err := os.ReadFile(pidFile)
+		if pid, errAtoi := strconv.Atoi(strings.TrimSpace(string(content))); err == nil && errAtoi == nil {
+			return pid
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("the module hasn't written its child's pid in %s", pidFile)
+
+	return 0
+}
+
+// Fails the test if the process is still running a second later. Zombies aren't running:
+// the process reaping orphans may not do it in a container.
+func expectStopped(t *testing.T, pid int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		state, _ := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+		if s := strings.TrimSpace(string(state)); s == "" || strings.HasPrefix(s, "Z") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	syscall.Kill(pid, syscall.SIGKILL)
+	t.Errorf("the module's child %d is still running", pid)
+}
+
+func TestModuleExecTimeoutStopsChildren(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	module := &Module{Name: "spawning", Path: writeSpawningModule(t, pidFile)}
+	b := &Brick{
+		Name:     "room/brick",
+		Path:     t.TempDir(),
+		Timeouts: map[string]time.Duration{"lay": 200 * time.Millisecond},
+	}
+
+	err := module.exec(b, "lay", nil, nil, os.Stdout, os.Stderr)
+	if !errors.As(err, &ExecTimeoutError{}) {
+		t.Errorf("exec() error = %v, expected an ExecTimeoutError", err)
+	}
+
+	expectStopped(t, readChildPid(t, pidFile))
+}
+
+// A module sharing exeiac's process group, as when stdin is a terminal
+func TestSignalModuleWithoutProcessGroup(t *testing.T) {
+	for _, stop := range []struct {
+		name string
+		fn   func(cmd *exec.Cmd) error
+	}{
+		{name: "signal", fn: func(cmd *exec.Cmd) error { return signalModule(cmd, syscall.SIGTERM) }},
+		{name: "kill", fn: killModule},
+	} {
+		t.Run(stop.name, func(t *testing.T) {
+			pidFile := filepath.Join(t.TempDir(), "child.pid")
+			cmd := exec.Command(writeSpawningModule(t, pidFile))
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			child := readChildPid(t, pidFile)
+
+			if err := stop.fn(cmd); err != nil {
+				t.Errorf("stopping the module: %v", err)
+			}
+			cmd.Wait()
+
+			expectStopped(t, child)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package infra
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+)
+
+// The directory, relative to the XDG cache directory, where bricks outputs are cached
+const OUTPUT_CACHE_DIR = "exeiac/outputs"
+
+// A brick output as stored in the cache
+type cachedOutput struct {
+	Brick string `json:"brick"`
+	// The absolute path of the brick, and of the configuration file of the infra it belongs to
+	Path              string `json:"path"`
+	ConfigurationFile string `json:"configuration_file"`
+	Module            string `json:"module"`
+	// A fingerprint of the brick's directory when the output was fetched
+	Fingerprint string `json:"fingerprint"`
+	Output      []byte `json:"output"`
+}
+
+// Returns the paths of the files written by exeiac in the brick directory
+func (b *Brick) InputFilePaths() (paths []string) {
+	for _, i := range b.Inputs {
+		path := filepath.Join(b.Path, i.Path)
+		if path != b.Path {
+			paths = append(paths, path)
+		}
+	}
+
+	return
+}
+
+// NOTE(half-shell): the brick's name isn't enough to identify it, since several infras, or
+// checkouts of the same infra, can have bricks with the same names
+func (infra *Infra) outputCacheFilePath(b *Brick) (string, error) {
+	hash := sha256.Sum256([]byte(infra.ConfigurationFile + "\x00" + b.Path))
+
+	return xdg.CacheFile(filepath.Join(OUTPUT_CACHE_DIR, hex.EncodeToString(hash[:])+".json"))
+}
+
+// Computes a fingerprint of the brick's module and of the files of the brick's directory
+// (names, sizes and modification times), ignoring the input files written by exeiac.
+// NOTE(half-shell): a remote state (e.g. a terraform backend) isn't part of the fingerprint.
+func (b *Brick) outputFingerprint() (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "module:%s:%s\n", b.Module.Name, b.Module.Path)
+
+	if info, err := os.Stat(b.Module.Path); err == nil {
+		fmt.Fprintf(hash, "module_file:%d:%d\n", info.Size(), info.ModTime().UnixNano())
+	}
+
+	inputFiles := make(map[string]bool)
+	for _, path := range b.InputFilePaths() {
+		inputFiles[path] = true
+	}
+
+	err := filepath.WalkDir(b.Path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || inputFiles[path] {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(b.Path, path)
+		fmt.Fprintf(hash, "file:%s:%d:%d\n", relPath, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Looks for the brick's output in the cache.
+// Returns the output if it has been cached with the same module and the brick's directory
+// hasn't changed since then.
+func (infra *Infra) LoadCachedOutput(b *Brick) (output []byte, found bool) {
+	path, err := infra.outputCacheFilePath(b)
+	if err != nil {
+		return
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var cached cachedOutput
+	if json.Unmarshal(content, &cached) != nil {
+		return
+	}
+
+	fingerprint, err := b.outputFingerprint()
+	if err != nil || cached.Brick != b.Name || cached.Path != b.Path ||
+		cached.ConfigurationFile != infra.ConfigurationFile || cached.Module != b.Module.Name ||
+		cached.Fingerprint != fingerprint {
+		return
+	}
+
+	return cached.Output, true
+}
+
+// Stores the brick's current `Output` in the cache.
+func (infra *Infra) CacheOutput(b *Brick) error {
+	path, err := infra.outputCacheFilePath(b)
+	if err != nil {
+		return err
+	}
+
+	fingerprint, err := b.outputFingerprint()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(cachedOutput{
+		Brick:             b.Name,
+		Path:              b.Path,
+		ConfigurationFile: infra.ConfigurationFile,
+		Module:            b.Module.Name,
+		Fingerprint:       fingerprint,
+		Output:            b.Output,
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
+
+// Removes the brick's output from the cache.
+func (infra *Infra) InvalidateCachedOutput(b *Brick) error {
+	path, err := infra.outputCacheFilePath(b)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
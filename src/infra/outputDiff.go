@@ -0,0 +1,133 @@
+package infra
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	OUTPUT_ADDED   = "added"
+	OUTPUT_REMOVED = "removed"
+	OUTPUT_CHANGED = "changed"
+)
+
+// A change of a value between two outputs of a brick.
+// NOTE(half-shell): values aren't kept since outputs can contain secrets, and changes are
+// displayed, written in logs and in reports.
+type OutputChange struct {
+	// The JSON path of the value
+	Path string `json:"path"`
+	// OUTPUT_ADDED, OUTPUT_REMOVED or OUTPUT_CHANGED
+	Kind string `json:"kind"`
+}
+
+// Keys that can be written with the dot notation in a JSON path
+var jsonPathKeyRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func (c OutputChange) String() string {
+	switch c.Kind {
+	case OUTPUT_ADDED:
+		return fmt.Sprintf("+ %s (added)", c.Path)
+	case OUTPUT_REMOVED:
+		return fmt.Sprintf("- %s (removed)", c.Path)
+	default:
+		return fmt.Sprintf("~ %s (changed)", c.Path)
+	}
+}
+
+func jsonPathChild(path string, key string) string {
+	if jsonPathKeyRegexp.MatchString(key) {
+		return path + "." + key
+	}
+
+	return fmt.Sprintf("%s['%s']", path, strings.ReplaceAll(key, "'", "\\'"))
+}
+
+func diffValues(path string, before interface{}, after interface{}) (changes []OutputChange) {
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, isMap := after.(map[string]interface{})
+		if !isMap {
+			break
+		}
+
+		var keys []string
+		for k := range b {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, exist := b[k]; !exist {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := jsonPathChild(path, k)
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+			if !inAfter {
+				changes = append(changes, OutputChange{Path: childPath, Kind: OUTPUT_REMOVED})
+			} else if !inBefore {
+				changes = append(changes, OutputChange{Path: childPath, Kind: OUTPUT_ADDED})
+			} else {
+				changes = append(changes, diffValues(childPath, bv, av)...)
+			}
+		}
+
+		return
+	case []interface{}:
+		a, isSlice := after.([]interface{})
+		if !isSlice {
+			break
+		}
+
+		for i := 0; i < len(b) || i < len(a); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= len(a) {
+				changes = append(changes, OutputChange{Path: childPath, Kind: OUTPUT_REMOVED})
+			} else if i >= len(b) {
+				changes = append(changes, OutputChange{Path: childPath, Kind: OUTPUT_ADDED})
+			} else {
+				changes = append(changes, diffValues(childPath, b[i], a[i])...)
+			}
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		changes = append(changes, OutputChange{Path: path, Kind: OUTPUT_CHANGED})
+	}
+
+	return
+}
+
+// Computes the changes between two JSON outputs of a brick, sorted by path.
+// An empty output is seen as an empty object.
+// Returns an error if an output isn't valid JSON.
+func DiffOutputs(before []byte, after []byte) ([]OutputChange, error) {
+	parse := func(output []byte) (value interface{}, err error) {
+		if len(strings.TrimSpace(string(output))) == 0 {
+			return map[string]interface{}{}, nil
+		}
+		err = json.Unmarshal(output, &value)
+
+		return
+	}
+
+	beforeValue, err := parse(before)
+	if err != nil {
+		return nil, fmt.Errorf("previous output is not valid JSON: %v", err)
+	}
+	afterValue, err := parse(after)
+	if err != nil {
+		return nil, fmt.Errorf("output is not valid JSON: %v", err)
+	}
+
+	return diffValues("$", beforeValue, afterValue), nil
+}
@@ -0,0 +1,41 @@
+package infra
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	before := []byte(`{"kept": 1, "password": "old-secret", "removed": "x", "list": [1, 2]}`)
+	after := []byte(`{"kept": 1, "password": "new-secret", "added": {"a": true}, "list": [1]}`)
+
+	changes, err := DiffOutputs(before, after)
+	if err != nil {
+		t.Fatalf("DiffOutputs(): %v", err)
+	}
+
+	expected := []OutputChange{
+		{Path: "$.added", Kind: OUTPUT_ADDED},
+		{Path: "$.list[1]", Kind: OUTPUT_REMOVED},
+		{Path: "$.password", Kind: OUTPUT_CHANGED},
+		{Path: "$.removed", Kind: OUTPUT_REMOVED},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("changes = %v, expected %v", changes, expected)
+	}
+
+	// NOTE(half-shell): values can be secrets, they must never be displayed nor reported
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		for _, displayed := range []string{c.String(), string(encoded)} {
+			if strings.Contains(displayed, "secret") {
+				t.Errorf("%q contains an output value", displayed)
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package infra
+
+import (
+	"context"
+	"os"
+)
+
+// Files can't be polled on this platform: reading them can't be cancelled once started
+func waitForInput(ctx context.Context, file *os.File) error {
+	return ctx.Err()
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package infra
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// How often the context is checked while waiting for an input
+const INPUT_POLL_INTERVAL_MS = 100
+
+// Waits until `file` can be read without blocking, or until the context is done.
+func waitForInput(ctx context.Context, file *os.File) error {
+	fds := []unix.PollFd{{Fd: int32(file.Fd()), Events: unix.POLLIN}}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ready, err := unix.Poll(fds, INPUT_POLL_INTERVAL_MS)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if ready > 0 {
+			return nil
+		}
+	}
+}
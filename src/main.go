@@ -6,6 +6,7 @@ import (
 	exaction "src/exeiac/actions"
 	exargs "src/exeiac/arguments"
 	excompletion "src/exeiac/completion"
+	_ "src/exeiac/drivers"
 	exinfra "src/exeiac/infra"
 	exstatuscode "src/exeiac/statuscode"
 
@@ -26,6 +27,8 @@ func main() {
 		return
 	}
 
+	exinfra.HandleInterruptions()
+
 	// The only remaining arguments are not flags. They match the action and the brickNames
 	nonFlagArgs := flag.Args()
 
@@ -70,7 +73,12 @@ func main() {
 	}
 
 	// enrich bricks that we will execute
-	infra.EnrichBricks()
+	err = infra.EnrichBricks()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(exstatuscode.ENRICH_ERROR)
+	}
 
 	// get bricks selected
 	var bricks exinfra.Bricks
@@ -3,6 +3,7 @@ package tools
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
@@ -31,20 +32,25 @@ func AskConfirmation(question string) (confirm bool, err error) {
 }
 
 func DisplaySeparator(separatorName string) {
+	DisplaySeparatorTo(os.Stdout, separatorName)
+}
+
+// Same as `DisplaySeparator` but writes the separator to the given writer.
+func DisplaySeparatorTo(w io.Writer, separatorName string) {
 	var s string
 	if separatorName == "" {
 		s = strings.Repeat("_", LINE_LENGTH)
-		fmt.Printf("\033[01;36m" + s + "\033[0m\n")
+		fmt.Fprintf(w, "\033[01;36m"+s+"\033[0m\n")
 	} else {
 		endLineLength := LINE_LENGTH - len(separatorName) - 4
 		if endLineLength > 0 {
 			s = strings.Repeat("-", endLineLength)
-			fmt.Printf("\033[01;36m-- " + separatorName + " " + s + "\033[0m\n")
+			fmt.Fprintf(w, "\033[01;36m-- "+separatorName+" "+s+"\033[0m\n")
 		} else {
 			lengthToDisplay := LINE_LENGTH - 3
 			s = "-- " + separatorName
 			s = s[0:lengthToDisplay]
-			fmt.Printf("\033[01;36m" + s + "..." + "\033[0m\n")
+			fmt.Fprintf(w, "\033[01;36m"+s+"..."+"\033[0m\n")
 		}
 	}
 }